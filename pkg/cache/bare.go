@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+//migrateBare converts a repo cached with a working tree into a bare repo, by moving its .git directory in place
+//of the working tree.  Bare repos are left untouched.
+func migrateBare(repoPath string) (err error) {
+	gitDir := filepath.Join(repoPath, git.GitDirName)
+	if _, err = os.Stat(gitDir); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	tmp := repoPath + ".bare"
+	if err = os.RemoveAll(tmp); err != nil {
+		return
+	}
+	if err = os.Rename(gitDir, tmp); err != nil {
+		return
+	}
+	if err = os.RemoveAll(repoPath); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, repoPath); err != nil {
+		return
+	}
+	// go-git only opens repos without a working tree when they're configured as bare
+	var s *filesystem.Storage
+	if s, err = filesystem.NewStorage(osfs.New(repoPath)); err != nil {
+		return
+	}
+	cfg, err := s.Config()
+	if err != nil {
+		return
+	}
+	cfg.Core.IsBare = true
+	cfg.Core.Worktree = ""
+	return s.SetConfig(cfg)
+}
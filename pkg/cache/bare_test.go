@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func Test_migrateBare(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	repoPath := filepath.Join(base, "refs")
+	writeRefsRepo(t, repoPath)
+	if err := migrateBare(repoPath); err != nil {
+		t.Fatalf("migrateBare() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repoPath, "master")); !os.IsNotExist(err) {
+		t.Errorf("migrateBare() left the working tree, stat error = %v", err)
+	}
+	rep, err := git.PlainOpen(repoPath)
+	if err != nil {
+		t.Fatalf("git.PlainOpen() error = %v", err)
+	}
+	if cfg, err := rep.Config(); err != nil || !cfg.Core.IsBare {
+		t.Errorf("migrateBare() didn't configure a bare repo, error = %v", err)
+	}
+	// Migrating again leaves the bare repo as is
+	if err := migrateBare(repoPath); err != nil {
+		t.Fatalf("migrateBare() error = %v", err)
+	}
+	got, err := NewGitCache(base).Stats("refs", &StatsOptions{Domains: []string{"thalesesec.net"}})
+	if err != nil || got.Commits != 1 {
+		t.Errorf("GitCache.Stats() = %+v, %v, want 1 commit", got, err)
+	}
+}
+
+func TestGitCache_Add_bare(t *testing.T) {
+	remote, err := ioutil.TempDir("", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	r := writeRefsRepo(t, remote)
+	// Clones only fetch the branches of the remote repo
+	rep, err := git.PlainOpen(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, hash := range map[string]plumbing.Hash{"feature": r.feature, "release-1.0": r.release} {
+		if err := rep.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(headsPrefix+name), hash)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name        string
+		depth       int
+		wantCommits int64
+	}{
+		{
+			name:        "Full",
+			wantCommits: 3,
+		}, {
+			name:        "Shallow",
+			depth:       1,
+			wantCommits: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ioutil.TempDir("", "cache")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(base)
+			gc := NewGitCacheWithOptions(base, GitCacheOptions{Depth: tt.depth})
+			// Adding twice clones, then fetches
+			for i := 0; i < 2; i++ {
+				if err := gc.Add("refs", "file://"+remote); err != nil {
+					t.Fatalf("GitCache.Add() error = %v", err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(base, "refs", git.GitDirName)); !os.IsNotExist(err) {
+				t.Errorf("GitCache.Add() didn't clone a bare repo, stat error = %v", err)
+			}
+			got, err := gc.Stats("refs", &StatsOptions{Domains: []string{"thalesesec.net"}, Refs: []string{RefsBranches}})
+			if err != nil {
+				t.Fatalf("GitCache.Stats() error = %v", err)
+			}
+			if got.Commits != tt.wantCommits {
+				t.Errorf("GitCache.Stats() commits = %v, want %v", got.Commits, tt.wantCommits)
+			}
+			rep, err := git.PlainOpen(filepath.Join(base, "refs"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := truncated(rep, &StatsOptions{}); got != (tt.depth > 0) {
+				t.Errorf("truncated() = %v, want %v", got, tt.depth > 0)
+			}
+			// A window after the boundary is whole
+			if truncated(rep, &StatsOptions{Since: time.Now()}) {
+				t.Errorf("truncated() = true for a window after the boundary")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+//DefaultBots are the patterns of well known bot and automation accounts, which are excluded unless disabled
+var DefaultBots = []string{
+	"*[bot]",
+	"*[bot]@users.noreply.github.com",
+	"dependabot*",
+	"renovate*",
+	"bot@renovateapp.com",
+	"greenkeeper*",
+	"github-actions",
+	"github-actions@github.com",
+	"action@github.com",
+	"snyk-bot*",
+	"semantic-release-bot*",
+}
+
+//bots are compiled patterns of bot names and email addresses
+type bots globs
+
+//newBots returns the bots for patterns, which are matched without regard to case
+func newBots(patterns []string) bots {
+	lower := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lower[i] = strings.ToLower(pattern)
+	}
+	return bots(newGlobs(lower))
+}
+
+//match reports whether a signature's name or email address belongs to a bot
+func (b bots) match(sig object.Signature) bool {
+	return globs(b).match(strings.ToLower(sig.Name)) || globs(b).match(strings.ToLower(sig.Email))
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thales-e-security/contribstats/pkg/testutil"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func Test_bots_match(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		sig      object.Signature
+		want     bool
+	}{
+		{
+			name:     "Dependabot",
+			patterns: DefaultBots,
+			sig:      object.Signature{Name: "dependabot[bot]", Email: "49699333+dependabot[bot]@users.noreply.github.com"},
+			want:     true,
+		}, {
+			name:     "Renovate",
+			patterns: DefaultBots,
+			sig:      object.Signature{Name: "Renovate Bot", Email: "bot@renovateapp.com"},
+			want:     true,
+		}, {
+			name:     "GitHub Actions",
+			patterns: DefaultBots,
+			sig:      object.Signature{Name: "github-actions", Email: "41898282+github-actions[bot]@users.noreply.github.com"},
+			want:     true,
+		}, {
+			name:     "Human",
+			patterns: DefaultBots,
+			sig:      object.Signature{Name: "Jane Doe", Email: "jane@thalesesec.net"},
+			want:     false,
+		}, {
+			name:     "Configured",
+			patterns: []string{"Release-Bot@*"},
+			sig:      object.Signature{Name: "CI", Email: "release-bot@thalesesec.net"},
+			want:     true,
+		}, {
+			name: "None",
+			sig:  object.Signature{Name: "dependabot[bot]"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newBots(tt.patterns).match(tt.sig); got != tt.want {
+				t.Errorf("bots.match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitCache_Stats_bots(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	repo := testutil.NewDiskRepo(t, filepath.Join(base, "bots"))
+	member := testutil.Sig("Jane", "jane@thalesesec.net")
+	bot := testutil.Sig("dependabot[bot]", "49699333+dependabot[bot]@users.noreply.github.com")
+	for i, author := range []object.Signature{member, bot, bot} {
+		name := fmt.Sprintf("file%d", i)
+		repo.Commit(testutil.Commit{
+			Author:    author,
+			Committer: &member,
+			Message:   "Add " + name,
+			Files:     map[string]string{name: name + "\n"},
+		})
+	}
+	got, err := NewGitCache(base).Stats("bots", &StatsOptions{Domains: []string{"thalesesec.net"}, Bots: DefaultBots})
+	if err != nil {
+		t.Fatalf("GitCache.Stats() error = %v", err)
+	}
+	if got.Commits != 1 || got.Bots != 2 {
+		t.Errorf("GitCache.Stats() commits = %v, bots = %v, want 1, 2", got.Commits, got.Bots)
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import "strings"
+
+//Counts are the matched commits and lines of a share of a repo, e.g. a contributor or a domain
+type Counts struct {
+	Commits   int64 `json:"commits"`
+	Additions int64 `json:"additions"`
+	Deletions int64 `json:"deletions"`
+}
+
+//Breakdown maps the keys of a share, e.g. contributor identities or domains, to their Counts
+type Breakdown map[string]*Counts
+
+//Add adds a commit's lines to a key and returns the resulting Breakdown, allocating it if nil
+func (b Breakdown) Add(key string, additions, deletions int64) Breakdown {
+	if b == nil {
+		b = make(Breakdown)
+	}
+	c, ok := b[key]
+	if !ok {
+		c = &Counts{}
+		b[key] = c
+	}
+	c.Commits = c.Commits + 1
+	c.Additions = c.Additions + additions
+	c.Deletions = c.Deletions + deletions
+	return b
+}
+
+//identity returns the canonical identity of an email address, its alias if it has one, or the lower case address
+func (o *StatsOptions) identity(email string) string {
+	email = strings.ToLower(email)
+	if alias, ok := o.Aliases[email]; ok {
+		return alias
+	}
+	return email
+}
+
+//domain returns the lower case domain of the email address if it is one of the domains, otherwise ""
+func (o *StatsOptions) domain(email string) string {
+	split := strings.Split(strings.ToLower(email), "@")
+	if len(split) != 2 {
+		return ""
+	}
+	for _, domain := range o.Domains {
+		if strings.ToLower(domain) == split[1] {
+			return split[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBreakdown_Add(t *testing.T) {
+	var c Breakdown
+	c = c.Add("jdoe", 3, 1).Add("jdoe", 2, 0).Add("jane@example.com", 1, 1)
+	want := Breakdown{
+		"jdoe":             {Commits: 2, Additions: 5, Deletions: 1},
+		"jane@example.com": {Commits: 1, Additions: 1, Deletions: 1},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Breakdown.Add() = %v, want %v", c, want)
+	}
+}
+
+func TestStatsOptions_identity(t *testing.T) {
+	opts := &StatsOptions{
+		Aliases: map[string]string{"1234+jdoe@users.noreply.github.com": "jdoe"},
+	}
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{
+			name:  "Alias",
+			email: "1234+JDoe@users.noreply.github.com",
+			want:  "jdoe",
+		}, {
+			name:  "Email",
+			email: "Jane@Example.com",
+			want:  "jane@example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.identity(tt.email); got != tt.want {
+				t.Errorf("StatsOptions.identity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsOptions_domain(t *testing.T) {
+	opts := &StatsOptions{
+		Domains: []string{"Thalesesec.net", "thales-e-security.com"},
+	}
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{
+			name:  "Match",
+			email: "someone@THALESESEC.net",
+			want:  "thalesesec.net",
+		}, {
+			name:  "Other",
+			email: "someone@example.com",
+			want:  "",
+		}, {
+			name:  "Invalid",
+			email: "someone",
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.domain(tt.email); got != tt.want {
+				t.Errorf("StatsOptions.domain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// autoPackLimit is the number of packs above which a cached repo is repacked, like "git gc --auto"
+var autoPackLimit = 50
+
+//RepoUsage is the disk usage of a cached repo.  Used is the last time it was added or had its stats computed.
+type RepoUsage struct {
+	Repo string    `json:"repo"`
+	Size int64     `json:"size"`
+	Used time.Time `json:"used"`
+	// Names are the names the repo was indexed by, see GitCache.Index
+	Names []string `json:"names,omitempty"`
+}
+
+//touch marks a cached repo as used now, for the least recently used eviction of GC
+func touch(repoPath string) {
+	now := time.Now()
+	if err := os.Chtimes(repoPath, now, now); err != nil {
+		logrus.Debugf("Failed to mark %v as used: %s", repoPath, err)
+	}
+}
+
+//isRepo reports whether a directory holds a cached repo, either bare or with a working tree
+func isRepo(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, git.GitDirName)); err == nil {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(dir, "HEAD"))
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && fi.IsDir()
+}
+
+//dirSize returns the total size of the files beneath a directory.  Files removed while walking, such as temporary
+//packs renamed by another instance sharing the cache, aren't counted.
+func dirSize(dir string) (size int64, err error) {
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			size = size + fi.Size()
+		}
+		return nil
+	})
+	return
+}
+
+//Usage returns the disk usage of every cached repo, the largest first
+func (gc *GitCache) Usage() (usage []*RepoUsage, err error) {
+	if _, err = os.Stat(gc.Path()); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var names map[string][]string
+	if names, err = gc.names(); err != nil {
+		return
+	}
+	err = filepath.Walk(gc.Path(), func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Clones are renamed into place, and repos removed, while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		// Clones in progress aren't cached yet, those left over by interrupted ones are removed like any other repo
+		if strings.Contains(fi.Name(), cloneSuffix) && time.Since(fi.ModTime()) < staleClone {
+			return filepath.SkipDir
+		}
+		if !isRepo(p) {
+			return nil
+		}
+		ru := &RepoUsage{Used: fi.ModTime()}
+		if ru.Repo, err = filepath.Rel(gc.Path(), p); err != nil {
+			return err
+		}
+		if ru.Size, err = dirSize(p); err != nil {
+			return err
+		}
+		ru.Names = names[ru.Repo]
+		usage = append(usage, ru)
+		// Repos don't nest
+		return filepath.SkipDir
+	})
+	sortUsage(usage)
+	return
+}
+
+//sortUsage sorts the usage of repos, the largest first
+func sortUsage(usage []*RepoUsage) {
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Size != usage[j].Size {
+			return usage[i].Size > usage[j].Size
+		}
+		return usage[i].Repo < usage[j].Repo
+	})
+}
+
+//GC removes the cached repos that aren't in keep, including quarantined ones, repacks those with too many packs, and then evicts the least
+//recently used repos while the cache is larger than its quota.  A nil keep removes nothing, and a zero quota is
+//unlimited.  Evicted repos are cloned again the next time they're added.  The usage of the repos left is returned,
+//the largest first, sparing another walk of the cache.
+func (gc *GitCache) GC(keep []string) (removed []string, usage []*RepoUsage, err error) {
+	var cached []*RepoUsage
+	if cached, err = gc.Usage(); err != nil {
+		return
+	}
+	// Removed repos lose their names too
+	defer func() {
+		if ierr := gc.unindex(removed); err == nil {
+			err = ierr
+		}
+	}()
+	kept := make(map[string]bool)
+	for _, repo := range keep {
+		kept[filepath.Clean(repo)] = true
+	}
+	for _, ru := range cached {
+		if keep != nil && !kept[ru.Repo] {
+			if err = gc.locked(ru.Repo, gc.remove); err != nil {
+				return removed, nil, err
+			}
+			removed = append(removed, ru.Repo)
+			continue
+		}
+		var repacked bool
+		if err := gc.locked(ru.Repo, func(repo string) (err error) {
+			repacked, err = gc.compact(repo)
+			return
+		}); err != nil {
+			logrus.Warnf("Failed to compact %v: %s", ru.Repo, err)
+		} else if repacked {
+			// Only repacked repos changed size
+			if ru.Size, err = dirSize(filepath.Join(gc.Path(), ru.Repo)); err != nil {
+				logrus.Warnf("Failed to size %v: %s", ru.Repo, err)
+			}
+		}
+		usage = append(usage, ru)
+	}
+	if gc.quota > 0 {
+		var total int64
+		for _, ru := range usage {
+			total = total + ru.Size
+		}
+		sort.Slice(usage, func(i, j int) bool {
+			return usage[i].Used.Before(usage[j].Used)
+		})
+		for len(usage) > 0 && total > gc.quota {
+			ru := usage[0]
+			if err = gc.locked(ru.Repo, gc.remove); err != nil {
+				return removed, nil, err
+			}
+			logrus.Infof("Evicted %v from the cache, over its quota", ru.Repo)
+			removed = append(removed, ru.Repo)
+			total = total - ru.Size
+			usage = usage[1:]
+		}
+	}
+	sortUsage(usage)
+	return
+}
+
+//remove deletes a cached repo, and its parent directories once empty
+func (gc *GitCache) remove(repo string) (err error) {
+	repoPath := filepath.Join(gc.Path(), repo)
+	if err = os.RemoveAll(repoPath); err != nil {
+		return errors.Wrap(err, repo)
+	}
+	logrus.Debugf("Removed %v from the cache", repo)
+	removeEmptyDirs(filepath.Dir(repoPath), gc.Path())
+	gc.removeLock(repo)
+	return
+}
+
+//removeEmptyDirs deletes a directory and its parents while they're empty, stopping short of root
+func removeEmptyDirs(dir, root string) {
+	for ; dir != root && dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		// Only empty directories can be removed
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+//compact repacks the objects of a cached repo into a single pack once it has more than autoPackLimit of them,
+//the equivalent of "git gc --auto", and returns whether it did
+func (gc *GitCache) compact(repo string) (repacked bool, err error) {
+	var rep *git.Repository
+	if rep, err = git.PlainOpen(filepath.Join(gc.Path(), repo)); err != nil {
+		return
+	}
+	pos, ok := rep.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return
+	}
+	packs, err := pos.ObjectPacks()
+	if err != nil || len(packs) <= autoPackLimit {
+		return
+	}
+	logrus.Debugf("Repacking %v, with %d packs", repo, len(packs))
+	// Repository.RepackObjects can't walk annotated tags, nor the missing parents of shallow clones
+	var objs []plumbing.Hash
+	if objs, err = reachableObjects(rep); err != nil {
+		return
+	}
+	pfw, ok := rep.Storer.(storer.PackfileWriter)
+	if !ok {
+		return
+	}
+	w, err := pfw.PackfileWriter()
+	if err != nil {
+		return
+	}
+	pack, err := packfile.NewEncoder(w, rep.Storer, false).Encode(objs, 10)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	for _, h := range packs {
+		if h == pack {
+			continue
+		}
+		if err = pos.DeleteOldObjectPackAndIndex(h, time.Time{}); err != nil {
+			return
+		}
+	}
+	repacked = true
+	// Loose objects are now packed too
+	if los, ok := rep.Storer.(storer.LooseObjectStorer); ok {
+		err = los.ForEachObjectHash(func(hash plumbing.Hash) error {
+			return los.DeleteLooseObject(hash)
+		})
+	}
+	return
+}
+
+//reachableObjects returns the hashes of the objects reachable from the references of a repo, leaving out those
+//missing from shallow clones
+func reachableObjects(rep *git.Repository) (objs []plumbing.Hash, err error) {
+	var stack []plumbing.Hash
+	var refs storer.ReferenceIter
+	if refs, err = rep.Storer.IterReferences(); err != nil {
+		return
+	}
+	if err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			stack = append(stack, ref.Hash())
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	seen := make(map[plumbing.Hash]bool)
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		obj, err := rep.Storer.EncodedObject(plumbing.AnyObject, hash)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, hash)
+		switch obj.Type() {
+		case plumbing.CommitObject:
+			commit, err := object.DecodeCommit(rep.Storer, obj)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(append(stack, commit.TreeHash), commit.ParentHashes...)
+		case plumbing.TreeObject:
+			tree, err := object.DecodeTree(rep.Storer, obj)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range tree.Entries {
+				stack = append(stack, entry.Hash)
+			}
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(rep.Storer, obj)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, tag.Target)
+		}
+	}
+	return
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+//writeCache writes a repo for each name into a new cache directory, each used an hour after the previous one
+func writeCache(t *testing.T, names ...string) (base string) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range names {
+		repoPath := filepath.Join(base, name)
+		writeRefsRepo(t, repoPath)
+		if err := migrateBare(repoPath); err != nil {
+			t.Fatal(err)
+		}
+		used = used.Add(time.Hour)
+		if err := os.Chtimes(repoPath, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func TestGitCache_Usage(t *testing.T) {
+	// A clone in progress isn't cached yet, unlike one left over by an interrupted clone
+	base := writeCache(t, "github.com/unorepo/uno", "github.com/unorepo/dos", "github.com/unorepo/tres.clone123",
+		"github.com/unorepo/cuatro.clone456")
+	defer os.RemoveAll(base)
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(base, "github.com", "unorepo", "tres.clone123"), now, now); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := NewGitCache(base).Usage()
+	if err != nil {
+		t.Fatalf("GitCache.Usage() error = %v", err)
+	}
+	var repos []string
+	for _, ru := range usage {
+		if ru.Size <= 0 || ru.Used.IsZero() {
+			t.Errorf("GitCache.Usage() %v size = %v, used = %v", ru.Repo, ru.Size, ru.Used)
+		}
+		repos = append(repos, ru.Repo)
+	}
+	sort.Strings(repos)
+	want := []string{
+		filepath.Join("github.com", "unorepo", "cuatro.clone456"),
+		filepath.Join("github.com", "unorepo", "dos"),
+		filepath.Join("github.com", "unorepo", "uno"),
+	}
+	if !reflect.DeepEqual(repos, want) {
+		t.Errorf("GitCache.Usage() repos = %v, want %v", repos, want)
+	}
+}
+
+func Test_dirSize(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	if err := ioutil.WriteFile(filepath.Join(base, "pack"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dirSize(base); err != nil || got != 100 {
+		t.Errorf("dirSize() = %v, %v, want 100", got, err)
+	}
+	// Files removed meanwhile aren't an error
+	if got, err := dirSize(filepath.Join(base, "removed")); err != nil || got != 0 {
+		t.Errorf("dirSize() of a removed directory = %v, %v, want 0", got, err)
+	}
+}
+
+func TestGitCache_GC(t *testing.T) {
+	uno := filepath.Join("github.com", "unorepo", "uno")
+	dos := filepath.Join("github.com", "unorepo", "dos")
+	tres := filepath.Join("github.com", "other", "tres")
+	tests := []struct {
+		name        string
+		keep        []string
+		quota       int64
+		wantRemoved []string
+		wantKept    []string
+	}{
+		{
+			name:     "Keep All",
+			wantKept: []string{tres, dos, uno},
+		}, {
+			name:        "Unreferenced",
+			keep:        []string{uno, dos},
+			wantRemoved: []string{tres},
+			wantKept:    []string{dos, uno},
+		}, {
+			name:        "Quota",
+			quota:       1,
+			wantRemoved: []string{uno, dos, tres},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := writeCache(t, uno, dos, tres)
+			defer os.RemoveAll(base)
+			gc := NewGitCacheWithOptions(base, GitCacheOptions{Quota: tt.quota})
+			removed, usage, err := gc.GC(tt.keep)
+			if err != nil {
+				t.Fatalf("GitCache.GC() error = %v", err)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("GitCache.GC() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			var kept []string
+			for _, ru := range usage {
+				kept = append(kept, ru.Repo)
+			}
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("GitCache.GC() usage = %v, want %v", kept, tt.wantKept)
+			}
+			for _, repo := range removed {
+				if _, err := os.Stat(filepath.Join(base, repo)); !os.IsNotExist(err) {
+					t.Errorf("GitCache.GC() left %v, stat error = %v", repo, err)
+				}
+				if _, err := os.Stat(gc.lockPath(repo)); !os.IsNotExist(err) {
+					t.Errorf("GitCache.GC() left the lock of %v, stat error = %v", repo, err)
+				}
+			}
+			if tt.keep != nil {
+				if _, err := os.Stat(filepath.Join(base, "github.com", "other")); !os.IsNotExist(err) {
+					t.Errorf("GitCache.GC() left the empty owner directory, stat error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGitCache_compact(t *testing.T) {
+	base := writeCache(t, "uno")
+	defer os.RemoveAll(base)
+	limit := autoPackLimit
+	autoPackLimit = -1
+	defer func() { autoPackLimit = limit }()
+	if repacked, err := NewGitCache(base).compact("uno"); err != nil || !repacked {
+		t.Fatalf("GitCache.compact() = %v, %v, want repacked", repacked, err)
+	}
+	rep, err := git.PlainOpen(filepath.Join(base, "uno"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packs, err := rep.Storer.(storer.PackedObjectStorer).ObjectPacks()
+	if err != nil || len(packs) != 1 {
+		t.Errorf("GitCache.compact() packs = %v, %v, want 1 pack", packs, err)
+	}
+	got, err := NewGitCache(base).Stats("uno", &StatsOptions{Domains: []string{"thalesesec.net"}, Refs: []string{RefsBranches}})
+	if err != nil || got.Commits != 3 {
+		t.Errorf("GitCache.Stats() = %+v, %v, want 3 commits", got, err)
+	}
+}
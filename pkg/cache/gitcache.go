@@ -7,10 +7,11 @@ import (
 	"github.com/spf13/viper"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 )
 
 //DefaultCache location for where to cache cloned/fetched repos
@@ -21,16 +22,41 @@ type GitCache struct {
 	basepath string
 	members  []string
 	domains  []string
+	depth    int
+	quota    int64
+	// mu guards the name index
+	mu sync.Mutex
+	// locks are the in-process locks of repos, see lock
+	locksMu sync.Mutex
+	locks   map[string]*sync.RWMutex
+}
+
+//GitCacheOptions controls how a GitCache clones and fetches repos
+type GitCacheOptions struct {
+	// Depth limits clones and fetches to that many commits from the tip of each branch, zero is unlimited.
+	// Commits beyond the depth, and those at its boundary, don't count towards the stats.  go-git can only clone and
+	// fetch to a depth, not since a date nor without blobs, so the depth isn't tied to the time window.
+	Depth int
+	// Quota is the number of bytes GC keeps the cache under, zero is unlimited
+	Quota int64
 }
 
 //NewGitCache returns a GitCache object in the location of "basepath".
 //If basepath is zero value then the DefaultCache variable will be used.
 func NewGitCache(basepath string) (gc *GitCache) {
+	return NewGitCacheWithOptions(basepath, GitCacheOptions{})
+}
+
+//NewGitCacheWithOptions returns a GitCache object in the location of "basepath", which clones and fetches as opts
+//say.  If basepath is zero value then the DefaultCache variable will be used.
+func NewGitCacheWithOptions(basepath string, opts GitCacheOptions) (gc *GitCache) {
 	if basepath == "" {
 		basepath = DefaultCache
 	}
 	gc = &GitCache{
 		basepath: basepath,
+		depth:    opts.Depth,
+		quota:    opts.Quota,
 	}
 	return gc
 }
@@ -40,8 +66,15 @@ func (gc *GitCache) Path() string {
 	return gc.basepath
 }
 
-//Add will add a given repo's name and it's clone URL to the cache for later processing
+//Add will add a given repo's name and it's clone URL to the cache for later processing, locking the repo meanwhile.
+//Repos are stored bare, as only their history is needed, and repos cached with a working tree are converted.
+//Repos that fail their integrity check are quarantined and cloned again.
 func (gc *GitCache) Add(reponame, url string) (err error) {
+	var unlock func()
+	if unlock, err = gc.lock(reponame, true); err != nil {
+		return
+	}
+	defer unlock()
 
 	bb := &bytes.Buffer{}
 	repoPath := filepath.Join(gc.Path(), reponame)
@@ -49,30 +82,38 @@ func (gc *GitCache) Add(reponame, url string) (err error) {
 	if _, err = os.Stat(repoPath); err != nil {
 		if os.IsNotExist(err) {
 			// Clone non-existing repos...
-			if rep, err = git.PlainClone(repoPath, false, &git.CloneOptions{
-				URL:        url,
-				Progress:   bb,
-				RemoteName: "origin",
-			}); err != nil {
+			if err = gc.clone(reponame, url); err != nil {
 				return
 			}
-			logrus.Debugf("Cloned %v into %v", reponame, repoPath)
 		} else {
 			return
 		}
 	} else {
-		// Fetch existing repos to keep them up to date
-		if rep, err = git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{
-			DetectDotGit: true,
-		}); err != nil {
+		if err = migrateBare(repoPath); err != nil {
 			err = errors.Wrap(err, reponame)
-
 			return
 		}
+		if rep, err = verify(repoPath); err != nil {
+			// An interrupted clone or fetch, start over
+			logrus.Warnf("Cache of %v is corrupt: %s", reponame, err)
+			if err = gc.quarantine(reponame); err != nil {
+				err = errors.Wrap(err, reponame)
+				return
+			}
+			if err = gc.clone(reponame, url); err != nil {
+				err = errors.Wrap(err, reponame)
+				return
+			}
+			touch(repoPath)
+			return
+		}
+		// Fetch existing repos to keep them up to date
 		if err = rep.Fetch(&git.FetchOptions{
 			RemoteName: "origin",
 			Progress:   bb,
 			Force:      true,
+			Tags:       git.AllTags,
+			Depth:      gc.depth,
 		}); err != nil {
 			if git.NoErrAlreadyUpToDate.Error() == err.Error() {
 				err = nil
@@ -83,53 +124,150 @@ func (gc *GitCache) Add(reponame, url string) (err error) {
 		}
 		//logrus.Debugf("Fetched %v in %v", reponame, repoPath)
 	}
+	touch(repoPath)
 	return
 }
 
 //Stats processes a given reponame for stats and returns the number of commits and lines of matched members, or domains.
-func (gc *GitCache) Stats(reponame string) (commits int64, lines int64, err error) {
+//A commit is matched when its committer, or author, belongs to a member or domain as the Match policy says.
+func (gc *GitCache) Stats(reponame string, opts *StatsOptions) (stats *RepoStats, err error) {
 	//logrus.Debugf("Processing repo '%s'", reponame)
 	var rep *git.Repository
-	var logs object.CommitIter
 	repoPath := filepath.Join(gc.Path(), reponame)
-	members := viper.GetStringSlice("members")
-	domains := viper.GetStringSlice("domains")
+	var unlock func()
+	if unlock, err = gc.lock(reponame, false); err != nil {
+		return
+	}
+	defer unlock()
 	if rep, err = git.PlainOpen(repoPath); err != nil {
 		return
 	}
-	if logs, err = rep.Log(&git.LogOptions{
-		Order: git.LogOrderDefault,
-		From:  plumbing.ZeroHash,
-	}); err != nil {
+	touch(repoPath)
+	return repoStats(rep, reponame, opts)
+}
+
+//repoStats returns the stats of an opened repo, for any Cache.  A nil opts falls back to the config.
+func repoStats(rep *git.Repository, reponame string, opts *StatsOptions) (stats *RepoStats, err error) {
+	var from []plumbing.Hash
+	if opts == nil {
+		opts = &StatsOptions{
+			Members: viper.GetStringSlice("members"),
+			Domains: viper.GetStringSlice("domains"),
+			Exclude: DefaultExclude,
+			Bots:    DefaultBots,
+		}
+	}
+	filter := newPathFilter(opts.Include, opts.Exclude)
+	bots := newBots(opts.Bots)
+	// Commits reachable from several of the selected refs are only processed once
+	if from, err = selectRefs(rep, opts.Refs); err != nil {
 		err = errors.Wrap(err, reponame)
 		return
 	}
-	// For each commit entry, let's process the contents
-	logs.ForEach(func(commit *object.Commit) (err error) {
-		split := strings.Split(commit.Committer.Email, "@")
-		var domain string
-		if len(split) == 2 {
-			domain = split[1]
-		}
-		// See if this commit was committed by an email address or domain we are looking for
-		if stringInSlice(commit.Committer.Email, members) || stringInSlice(domain, domains) {
-			// increment the commit count
-			commits = commits + 1
-			var newLines int64
-			newLines, err = getLines(commit)
-			if err != nil {
-				logrus.Errorf("[%s] failed to get lines for commit %s: %s", reponame, commit.Hash, err)
-				return
+	// Netting out reverts needs to know every reverted commit up front, as they're logged after their reverts
+	var reverted map[plumbing.Hash]bool
+	if opts.Reverts == RevertsNet {
+		if reverted, err = revertedCommits(rep, from); err != nil {
+			err = errors.Wrap(err, reponame)
+			return
+		}
+	}
+	stats = &RepoStats{}
+	// For each commit entry, let's process the contents.  A missing object fails the stats rather than leaving them
+	// partial.
+	err = walk(rep, from, func(commit *object.Commit) (err error) {
+		// Skip commits outside of the time window
+		if !opts.inWindow(commit) {
+			return
+		}
+		// See if this commit was committed, or authored, by an email address or domain we are looking for
+		matched := opts.matched(commit)
+		if matched == nil {
+			return
+		}
+		// Bots aren't contributors, however much they commit with our addresses
+		if bots.match(commit.Author) {
+			stats.Bots = stats.Bots + 1
+			return
+		}
+		switch {
+		case commit.NumParents() > 1:
+			// Merges only repeat the lines of the merged commits, so at most they count as a commit
+			if opts.Merges == MergesCount {
+				stats.add(commit, matched, opts, &lineCounts{})
 			}
-			lines = lines + newLines
+			return
+		case opts.Reverts != RevertsCount && isRevert(commit):
+			return
+		case reverted[commit.Hash]:
+			return
+		}
+		var counts *lineCounts
+		counts, err = getLines(commit, filter)
+		if err != nil {
+			return errors.Wrapf(err, "lines of commit %s", commit.Hash)
 		}
+		stats.add(commit, matched, opts, counts)
 		return
 	})
-
+	if err != nil {
+		return nil, errors.Wrap(err, reponame)
+	}
+	if truncated(rep, opts) {
+		logrus.Warnf("[%s] the clone depth cuts into the window, its older commits aren't counted", reponame)
+	}
 	return
 }
 
-func getLines(commit CommitIface) (lines int64, err error) {
+//truncated reports whether the shallow boundary of a repo falls within the window of opts, so that commits of the
+//window are missing from the cache
+func truncated(rep *git.Repository, opts *StatsOptions) bool {
+	hashes, err := rep.Storer.Shallow()
+	if err != nil {
+		return false
+	}
+	for _, hash := range hashes {
+		if commit, err := rep.CommitObject(hash); err == nil && opts.inWindow(commit) {
+			return true
+		}
+	}
+	return false
+}
+
+//add aggregates a matched commit and its lines into the stats, crediting the contributor and domain of the matched
+//signature, so the outsiders whose commits members commit aren't listed
+func (stats *RepoStats) add(commit *object.Commit, matched *object.Signature, opts *StatsOptions, counts *lineCounts) {
+	// increment the commit count
+	stats.Commits = stats.Commits + 1
+	stats.Additions = stats.Additions + counts.additions
+	stats.Deletions = stats.Deletions + counts.deletions
+	stats.Lines = stats.Additions + stats.Deletions
+	stats.Excluded = stats.Excluded + counts.excluded
+	stats.Languages = stats.Languages.Merge(counts.languages)
+	stats.Contributors = stats.Contributors.Add(opts.identity(matched.Email), counts.additions, counts.deletions)
+	if domain := opts.domain(matched.Email); domain != "" {
+		stats.Domains = stats.Domains.Add(domain, counts.additions, counts.deletions)
+	}
+	stats.Series = stats.Series.Add(Bucket{
+		Start:     BucketStart(opts.when(commit), opts.Bucket),
+		Commits:   1,
+		Additions: counts.additions,
+		Deletions: counts.deletions,
+	})
+}
+
+//lineCounts are the lines changed by a commit
+type lineCounts struct {
+	additions int64
+	deletions int64
+	// excluded is the number of lines changed in files excluded by the pathFilter
+	excluded  int64
+	languages Languages
+}
+
+//getLines returns the number of lines added and deleted by a commit compared to its first parent, the lines
+//of files excluded by the filter are only counted as excluded.  A nil filter excludes nothing.
+func getLines(commit CommitIface, filter *pathFilter) (counts *lineCounts, err error) {
 	// Get the lines from this commit and it's parent
 	var tree *object.Tree
 	var treeDiff object.Changes
@@ -151,27 +289,41 @@ func getLines(commit CommitIface) (lines int64, err error) {
 			return
 		}
 	}
-	// Get the Diff of the commit tree vs the parent
-	if treeDiff, err = tree.Diff(parentTree); err != nil {
+	// Get the Diff of the parent tree vs the commit, a missing parent tree is treated as empty
+	if treeDiff, err = object.DiffTree(parentTree, tree); err != nil {
 		return
 	}
 	// Get the patch of the treeDiff for processing
 	if patch, err = treeDiff.Patch(); err != nil {
 		return
 	}
+	counts = &lineCounts{}
+	attrs := readAttributes(tree)
 	// Iterate over the FilePatches in this diff
 	for _, p := range patch.FilePatches() {
 		// If it's binary in nature, let's skip it... we only want source code lines.
 		if p.IsBinary() {
 			continue
 		}
-		// Range over the chunks in a given filepatch
+		var additions, deletions int64
+		// Range over the chunks in a given filepatch, unchanged context doesn't count
 		for _, chunk := range p.Chunks() {
-			// Line count is very blunt... maybe some tuning to be more honest in the future
-			// TODO make this more real life
-			ll := strings.Split(chunk.Content(), "\n")
-			lines = lines + int64(len(ll))
+			switch chunk.Type() {
+			case diff.Add:
+				additions = additions + countLines(chunk.Content())
+			case diff.Delete:
+				deletions = deletions + countLines(chunk.Content())
+			}
+		}
+		path := patchPath(p)
+		a := attrs.lookup(path)
+		if filter.excluded(path, a) {
+			counts.excluded = counts.excluded + additions + deletions
+			continue
 		}
+		counts.additions = counts.additions + additions
+		counts.deletions = counts.deletions + deletions
+		counts.languages = counts.languages.Add(language(path, a), additions, deletions)
 	}
 	return
 }
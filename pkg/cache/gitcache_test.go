@@ -6,17 +6,11 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/thales-e-security/contribstats/pkg/config"
-	"gopkg.in/src-d/go-billy.v4/memfs"
-	"gopkg.in/src-d/go-billy.v4/util"
-	"gopkg.in/src-d/go-git-fixtures.v3"
-	"gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/plumbing"
+	"github.com/thales-e-security/contribstats/pkg/testutil"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
-	"gopkg.in/src-d/go-git.v4/storage/memory"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"time"
 )
 
 func init() {
@@ -74,9 +68,17 @@ func TestGitCache_Path(t *testing.T) {
 func TestGitCache_Add(t *testing.T) {
 	td, _ := ioutil.TempDir("", "")
 	td2, _ := ioutil.TempDir("", "")
+	remote, _ := ioutil.TempDir("", "")
+	writeRefsRepo(t, remote)
+	// The default cache is a temporary one too
+	defaultCache := DefaultCache
+	DefaultCache, _ = ioutil.TempDir("", "")
 	defer func() {
 		os.RemoveAll(td)
 		//os.RemoveAll(td2)
+		os.RemoveAll(remote)
+		os.RemoveAll(DefaultCache)
+		DefaultCache = defaultCache
 	}()
 	type args struct {
 		repo string
@@ -95,7 +97,7 @@ func TestGitCache_Add(t *testing.T) {
 			gc:   NewGitCache(td),
 			args: args{
 				repo: "github.com/unorepo/uno",
-				url:  "https://github.com/unorepo/uno.git",
+				url:  "file://" + remote,
 			},
 			wantErr: false,
 		},
@@ -104,7 +106,7 @@ func TestGitCache_Add(t *testing.T) {
 			gc:   NewGitCache(""),
 			args: args{
 				repo: "github.com/unorepo/uno",
-				url:  "https://github.com/unorepo/uno.git",
+				url:  "file://" + remote,
 			},
 			wantErr: false,
 		}, {
@@ -118,16 +120,16 @@ func TestGitCache_Add(t *testing.T) {
 			},
 			wantErr: true,
 		}, {
-			name: "Error BadClonin",
+			name: "OK BadClonin",
 			gc: &GitCache{
 				basepath: td2,
 			},
 			args: args{
 				repo: "github.com/unorepo/uno",
-				url:  "https://github.com/unorepo/uno.git",
+				url:  "file://" + remote,
 			},
 			badClone: true,
-			wantErr:  true,
+			wantErr:  false,
 		},
 		// TODO: Force a Bad Fetch to happen
 		//
@@ -146,11 +148,11 @@ func TestGitCache_Add(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gitDir := filepath.Join(tt.gc.Path(), tt.args.repo, ".git")
+			gitDir := filepath.Join(tt.gc.Path(), tt.args.repo)
 			if tt.badClone {
 				// clone first, then mess it up
 				tt.gc.Add(tt.args.repo, tt.args.url)
-				os.RemoveAll(gitDir)
+				os.Remove(filepath.Join(gitDir, "HEAD"))
 			}
 			if tt.badConfig {
 				tt.gc.Add(tt.args.repo, tt.args.url)
@@ -164,6 +166,22 @@ func TestGitCache_Add(t *testing.T) {
 }
 
 func TestGitCache_Stats(t *testing.T) {
+	td, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	repo := testutil.NewDiskRepo(t, filepath.Join(td, "github.com/unorepo/uno"))
+	repo.Commit(testutil.Commit{
+		Author:  testutil.Sig("Someone", "someone@example.com"),
+		Message: "Initial",
+		Files:   map[string]string{"README.md": "# uno\n"},
+	})
+	repo.Commit(testutil.Commit{
+		Author:  testutil.Sig("Jane", "jane@thalesesec.net"),
+		Message: "Add uno",
+		Files:   map[string]string{"uno.go": "package uno\n", "LICENSE": "MIT\n"},
+	})
 	type args struct {
 		repo    string
 		members []string
@@ -178,16 +196,17 @@ func TestGitCache_Stats(t *testing.T) {
 	}{
 		{
 			name: "OK",
-			gc:   NewGitCache(DefaultCache),
+			gc:   NewGitCache(td),
 			args: args{
-				repo: "github.com/unorepo/uno",
+				repo:    "github.com/unorepo/uno",
+				members: []string{"jane@thalesesec.net"},
 			},
 			wantCommits: 1,
 			wantLines:   2,
 			wantErr:     false,
 		}, {
 			name: "Error",
-			gc:   NewGitCache(DefaultCache),
+			gc:   NewGitCache(td),
 			args: args{
 				repo: "github.com/notreallyhere/repo",
 			},
@@ -196,39 +215,131 @@ func TestGitCache_Stats(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotCommits, gotLines, err := tt.gc.Stats(tt.args.repo)
+			gotStats, err := tt.gc.Stats(tt.args.repo, &StatsOptions{Members: tt.args.members})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GitCache.Stats() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotCommits != tt.wantCommits {
-				t.Errorf("GitCache.Stats() gotCommits = %v, want %v", gotCommits, tt.wantCommits)
+			if tt.wantErr {
+				return
+			}
+			if gotStats.Commits != tt.wantCommits {
+				t.Errorf("GitCache.Stats() gotCommits = %v, want %v", gotStats.Commits, tt.wantCommits)
 			}
-			if gotLines != tt.wantLines {
-				t.Errorf("GitCache.Stats() gotLines = %v, want %v", gotLines, tt.wantLines)
+			if gotStats.Lines != tt.wantLines {
+				t.Errorf("GitCache.Stats() gotLines = %v, want %v", gotStats.Lines, tt.wantLines)
 			}
 		})
 	}
 }
 
+func TestGitCache_Stats_history(t *testing.T) {
+	td, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	repo := testutil.NewDiskRepo(t, filepath.Join(td, "history"))
+	jane := testutil.Sig("Jane", "jane@thalesesec.net")
+	repo.Commit(testutil.Commit{
+		Author: testutil.Sig("Someone", "someone@example.com"),
+		Files:  map[string]string{"README": "readme\n"},
+	})
+	repo.Commit(testutil.Commit{
+		Author: jane,
+		Files:  map[string]string{"main.go": "1\n2\n3\n"},
+		Binary: map[string][]byte{"logo.png": {0x89, 'P', 'N', 'G', 0}},
+	})
+	// Committed by a member on behalf of someone else
+	repo.Commit(testutil.Commit{
+		Author:    testutil.Sig("John", "john@example.com"),
+		Committer: &jane,
+		Trailers:  []string{"Signed-off-by: Jane <jane@thalesesec.net>"},
+		Files:     map[string]string{"main.go": "1\ntwo\n3\n"},
+	})
+	// Renames aren't detected, so they count as deleting and adding every line
+	repo.Commit(testutil.Commit{
+		Author: jane,
+		Rename: map[string]string{"main.go": "cmd.go"},
+	})
+	repo.Commit(testutil.Commit{
+		Author: jane,
+		Delete: []string{"logo.png"},
+	})
+	got, err := NewGitCache(td).Stats("history", &StatsOptions{Domains: []string{"thalesesec.net"}})
+	if err != nil {
+		t.Fatalf("GitCache.Stats() error = %v", err)
+	}
+	if got.Commits != 4 || got.Additions != 7 || got.Deletions != 4 || got.Lines != 11 {
+		t.Errorf("GitCache.Stats() commits = %v, additions = %v, deletions = %v, lines = %v, want 4, 7, 4, 11",
+			got.Commits, got.Additions, got.Deletions, got.Lines)
+	}
+	// John's commit is credited to Jane, who committed it
+	want := Breakdown{"jane@thalesesec.net": {Commits: 4, Additions: 7, Deletions: 4}}
+	if !reflect.DeepEqual(got.Contributors, want) {
+		t.Errorf("GitCache.Stats() contributors = %v, want %v", got.Contributors, want)
+	}
+	// Matching authors leaves out the commit of John
+	got, err = NewGitCache(td).Stats("history", &StatsOptions{Domains: []string{"thalesesec.net"}, Match: MatchAuthor})
+	if err != nil {
+		t.Fatalf("GitCache.Stats() error = %v", err)
+	}
+	if got.Commits != 3 || got.Additions != 6 || got.Deletions != 3 {
+		t.Errorf("GitCache.Stats() matching authors commits = %v, additions = %v, deletions = %v, want 3, 6, 3",
+			got.Commits, got.Additions, got.Deletions)
+	}
+}
+
+func TestGitCache_Stats_missingObject(t *testing.T) {
+	td, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	repo := testutil.NewDiskRepo(t, filepath.Join(td, "broken"))
+	jane := testutil.Sig("Jane", "jane@thalesesec.net")
+	first := repo.Commit(testutil.Commit{Author: jane, Files: map[string]string{"main.go": "1\n"}})
+	repo.Commit(testutil.Commit{Author: jane, Files: map[string]string{"main.go": "1\n2\n"}})
+	// As an interrupted fetch or a broken pack would leave it
+	object := first.String()
+	if err := os.Remove(filepath.Join(repo.Path, ".git", "objects", object[:2], object[2:])); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewGitCache(td).Stats("broken", &StatsOptions{Domains: []string{"thalesesec.net"}}); err == nil {
+		t.Errorf("GitCache.Stats() = %+v, want an error for the missing commit", got)
+	}
+}
+
 func Test_getLines(t *testing.T) {
 
 	type args struct {
 		commit CommitIface
+		filter *pathFilter
 	}
 	tests := []struct {
-		name      string
-		args      args
-		wantLines int64
-		wantErr   bool
+		name          string
+		args          args
+		wantAdditions int64
+		wantDeletions int64
+		wantExcluded  int64
+		wantErr       bool
 	}{
 		{
 			name: "OK",
 			args: args{
 				commit: getGoodCommit(t),
 			},
-			wantLines: 1,
-			wantErr:   false,
+			wantAdditions: 1,
+			wantDeletions: 0,
+			wantErr:       false,
+		}, {
+			name: "OK Excluded",
+			args: args{
+				commit: getGoodCommit(t),
+				filter: newPathFilter(nil, []string{"foo"}),
+			},
+			wantExcluded: 1,
+			wantErr:      false,
 		}, {
 			name: "ErrorTree",
 			args: args{
@@ -262,67 +373,40 @@ func Test_getLines(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotLines, err := getLines(tt.args.commit)
+			gotCounts, err := getLines(tt.args.commit, tt.args.filter)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getLines() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotLines != tt.wantLines {
-				t.Errorf("getLines() = %v, want %v", gotLines, tt.wantLines)
+			if tt.wantErr {
+				return
+			}
+			if gotCounts.additions != tt.wantAdditions {
+				t.Errorf("getLines() additions = %v, want %v", gotCounts.additions, tt.wantAdditions)
+			}
+			if gotCounts.deletions != tt.wantDeletions {
+				t.Errorf("getLines() deletions = %v, want %v", gotCounts.deletions, tt.wantDeletions)
+			}
+			if gotCounts.excluded != tt.wantExcluded {
+				t.Errorf("getLines() excluded = %v, want %v", gotCounts.excluded, tt.wantExcluded)
 			}
 		})
 	}
 }
 
 func getGoodCommit(t *testing.T) (c *object.Commit) {
-	var repo *git.Repository
-	var wt *git.Worktree
-	var hash plumbing.Hash
-	var err error
-	// Clone an existing fixture repo to manipulate
-	repo, err = git.Clone(memory.NewStorage(), memfs.New(), &git.CloneOptions{
-		URL: fixtures.Basic().One().URL,
+	repo := testutil.NewRepo(t)
+	repo.Commit(testutil.Commit{
+		Author:  testutil.Sig("Someone", "someone@example.com"),
+		Message: "Initial",
+		Files:   map[string]string{"README": "readme\n"},
 	})
-	if err != nil {
-		t.Error(err)
-	}
-	// Get the worktree to manipulate
-	wt, err = repo.Worktree()
-	if err != nil {
-		t.Error(err)
-	}
-
-	// Create a temp file to add to it.
-	err = util.WriteFile(wt.Filesystem, "foo", []byte("test"), 0755)
-	if err != nil {
-		t.Error(err)
-	}
-
-	//Add  path to the worktree
-	_, err = wt.Add("foo")
-	if err != nil {
-		t.Error(err)
-	}
-
-	hash, err = wt.Commit("test", &git.CommitOptions{
-		All: true,
-		Author: &object.Signature{
-			Name:  "John Candy",
-			Email: "john@candy.com",
-			When:  time.Now(),
-		},
-		Committer: &object.Signature{
-			Name:  "John Candy",
-			Email: "john@candy.com",
-			When:  time.Now(),
-		},
-		Parents: []plumbing.Hash{},
+	hash := repo.Commit(testutil.Commit{
+		Author:  testutil.Sig("John Candy", "john@candy.com"),
+		Message: "test",
+		Files:   map[string]string{"foo": "test"},
 	})
-	if err != nil {
-		t.Error(err)
-	}
-	//hash := plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47")
-	c, err = repo.CommitObject(hash)
+	c, err := repo.Repository.CommitObject(hash)
 	if err != nil {
 		t.Error(err)
 	}
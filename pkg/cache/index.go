@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// indexFile is the file of the cache that maps the names of repos to the keys they're cached under
+const indexFile = "index.json"
+
+//Index records name as a name of a cached repo, e.g. its full name when repos are keyed by a stable ID.  A repo
+//cached under name, before it was known by its key, is moved to the key rather than cloned again.
+func (gc *GitCache) Index(repo, name string) (err error) {
+	repo, name = filepath.Clean(repo), filepath.Clean(name)
+	if repo == name {
+		return
+	}
+	if err = gc.addName(repo, name); err != nil {
+		return
+	}
+	return gc.locked(repo, func(repo string) error {
+		return gc.adopt(repo, name)
+	})
+}
+
+//addName maps name to repo in the index
+func (gc *GitCache) addName(repo, name string) (err error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	index, err := gc.readIndex()
+	if err != nil || index[name] == repo {
+		return
+	}
+	index[name] = repo
+	return gc.writeIndex(index)
+}
+
+//adopt moves a repo cached under name to repo, unless repo is cached already.  The caller holds the lock of repo.
+func (gc *GitCache) adopt(repo, name string) (err error) {
+	repoPath := filepath.Join(gc.Path(), repo)
+	namePath := filepath.Join(gc.Path(), name)
+	if _, err = os.Stat(repoPath); !os.IsNotExist(err) {
+		return
+	}
+	if !isRepo(namePath) {
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(repoPath), 0755); err != nil {
+		return
+	}
+	if err = os.Rename(namePath, repoPath); err != nil {
+		return
+	}
+	logrus.Infof("Moved the cache of %v to %v", name, repo)
+	return
+}
+
+//names returns the names of every indexed repo, sorted
+func (gc *GitCache) names() (names map[string][]string, err error) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	index, err := gc.readIndex()
+	if err != nil {
+		return
+	}
+	names = make(map[string][]string)
+	for name, repo := range index {
+		names[repo] = append(names[repo], name)
+	}
+	for _, n := range names {
+		sort.Strings(n)
+	}
+	return
+}
+
+//unindex removes the names of repos from the index
+func (gc *GitCache) unindex(repos []string) (err error) {
+	if len(repos) == 0 {
+		return
+	}
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	index, err := gc.readIndex()
+	if err != nil {
+		return
+	}
+	removed := make(map[string]bool)
+	for _, repo := range repos {
+		removed[repo] = true
+	}
+	for name, repo := range index {
+		if removed[repo] {
+			delete(index, name)
+		}
+	}
+	return gc.writeIndex(index)
+}
+
+//readIndex reads the index of the cache, a missing index is empty.  The caller holds gc.mu.
+func (gc *GitCache) readIndex() (index map[string]string, err error) {
+	index = make(map[string]string)
+	b, err := ioutil.ReadFile(filepath.Join(gc.Path(), indexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &index); err != nil {
+		err = errors.Wrap(err, indexFile)
+	}
+	return
+}
+
+//writeIndex replaces the index of the cache, atomically.  The caller holds gc.mu.
+func (gc *GitCache) writeIndex(index map[string]string) (err error) {
+	b, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(gc.Path(), 0755); err != nil {
+		return
+	}
+	tmp := filepath.Join(gc.Path(), indexFile+".tmp")
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return
+	}
+	return os.Rename(tmp, filepath.Join(gc.Path(), indexFile))
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGitCache_Index(t *testing.T) {
+	key := filepath.Join("github.com", "id", "1234")
+	legacy := filepath.Join("github.com", "unorepo", "uno")
+	renamed := filepath.Join("github.com", "unorepo", "one")
+	// A cache from before repos were keyed by ID
+	base := writeCache(t, legacy)
+	defer os.RemoveAll(base)
+	gc := NewGitCache(base)
+	if err := gc.Index(key, legacy); err != nil {
+		t.Fatalf("GitCache.Index() error = %v", err)
+	}
+	if !isRepo(filepath.Join(base, key)) || isRepo(filepath.Join(base, legacy)) {
+		t.Errorf("GitCache.Index() didn't move %v to %v", legacy, key)
+	}
+	// A rename keeps the cached repo
+	if err := gc.Index(key, renamed); err != nil {
+		t.Fatalf("GitCache.Index() error = %v", err)
+	}
+	if !isRepo(filepath.Join(base, key)) {
+		t.Errorf("GitCache.Index() lost %v", key)
+	}
+	usage, err := gc.Usage()
+	if err != nil || len(usage) != 1 {
+		t.Fatalf("GitCache.Usage() = %v, %v, want 1 repo", usage, err)
+	}
+	if want := []string{renamed, legacy}; usage[0].Repo != key || !reflect.DeepEqual(usage[0].Names, want) {
+		t.Errorf("GitCache.Usage() = %v %v, want %v %v", usage[0].Repo, usage[0].Names, key, want)
+	}
+	// Removed repos leave the index
+	if _, _, err := gc.GC([]string{}); err != nil {
+		t.Fatalf("GitCache.GC() error = %v", err)
+	}
+	if names, err := gc.names(); err != nil || len(names) != 0 {
+		t.Errorf("GitCache.names() = %v, %v, want none", names, err)
+	}
+}
+
+func TestGitCache_Index_new(t *testing.T) {
+	base := writeCache(t)
+	defer os.RemoveAll(base)
+	gc := NewGitCache(base)
+	if err := gc.Index(filepath.Join("github.com", "id", "1234"), filepath.Join("github.com", "unorepo", "uno")); err != nil {
+		t.Errorf("GitCache.Index() error = %v", err)
+	}
+}
+
+func TestGitCache_Index_same(t *testing.T) {
+	base := writeCache(t, "uno")
+	defer os.RemoveAll(base)
+	gc := NewGitCache(base)
+	if err := gc.Index("uno", "uno"); err != nil {
+		t.Fatalf("GitCache.Index() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, indexFile)); !os.IsNotExist(err) {
+		t.Errorf("GitCache.Index() wrote an index for a repo keyed by name, stat error = %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// quarantineDir is the directory of the cache that broken repos are moved to, until GC removes them
+const quarantineDir = ".quarantine"
+
+// cloneSuffix follows the name of a repo in the name of the temporary directory it's cloned into
+const cloneSuffix = ".clone"
+
+// staleClone is the age after which the temporary directory of a clone is left over from an interrupted one
+const staleClone = 24 * time.Hour
+
+//verify opens a cached repo and checks its integrity: HEAD must resolve to a readable commit and tree, and every
+//reference must point to an object that exists.  A repo without any commits yet is valid.
+func verify(repoPath string) (rep *git.Repository, err error) {
+	if rep, err = git.PlainOpen(repoPath); err != nil {
+		return
+	}
+	head, err := rep.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return rep, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "HEAD")
+	}
+	commit, err := rep.CommitObject(head.Hash())
+	if err != nil {
+		return nil, errors.Wrap(err, "HEAD commit")
+	}
+	if _, err = commit.Tree(); err != nil {
+		return nil, errors.Wrap(err, "HEAD tree")
+	}
+	var refs storer.ReferenceIter
+	if refs, err = rep.References(); err != nil {
+		return nil, err
+	}
+	if err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		if _, err := rep.Storer.EncodedObject(plumbing.AnyObject, ref.Hash()); err != nil {
+			return errors.Wrap(err, ref.Name().String())
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return
+}
+
+//clone clones a repo into a temporary directory next to its place in the cache, and renames it into place once
+//complete, so an interrupted clone never leaves a broken repo behind
+func (gc *GitCache) clone(reponame, url string) (err error) {
+	repoPath := filepath.Join(gc.Path(), reponame)
+	if err = os.MkdirAll(filepath.Dir(repoPath), 0755); err != nil {
+		return
+	}
+	var tmp string
+	if tmp, err = ioutil.TempDir(filepath.Dir(repoPath), filepath.Base(repoPath)+cloneSuffix); err != nil {
+		return
+	}
+	defer os.RemoveAll(tmp)
+	if _, err = git.PlainClone(tmp, true, &git.CloneOptions{
+		URL:        url,
+		Progress:   &bytes.Buffer{},
+		RemoteName: "origin",
+		Depth:      gc.depth,
+	}); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, repoPath); err != nil {
+		return
+	}
+	logrus.Debugf("Cloned %v into %v", reponame, repoPath)
+	return
+}
+
+//quarantine moves a broken repo out of the way, into the quarantine directory of the cache
+func (gc *GitCache) quarantine(reponame string) (err error) {
+	dest := filepath.Join(gc.Path(), quarantineDir, reponame+"."+time.Now().UTC().Format("20060102T150405"))
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return
+	}
+	if err = os.Rename(filepath.Join(gc.Path(), reponame), dest); err != nil {
+		return
+	}
+	logrus.Warnf("Quarantined %v into %v", reponame, dest)
+	return
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_verify(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(repoPath string) error
+		wantErr bool
+	}{
+		{
+			name:    "OK",
+			corrupt: func(repoPath string) error { return nil },
+		}, {
+			name: "Error HEAD",
+			corrupt: func(repoPath string) error {
+				return os.Remove(filepath.Join(repoPath, "HEAD"))
+			},
+			wantErr: true,
+		}, {
+			name: "Error Objects",
+			corrupt: func(repoPath string) error {
+				if err := os.RemoveAll(filepath.Join(repoPath, "objects")); err != nil {
+					return err
+				}
+				return os.Mkdir(filepath.Join(repoPath, "objects"), 0755)
+			},
+			wantErr: true,
+		}, {
+			name: "Error Missing",
+			corrupt: func(repoPath string) error {
+				return os.RemoveAll(repoPath)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := writeCache(t, "uno")
+			defer os.RemoveAll(base)
+			repoPath := filepath.Join(base, "uno")
+			if err := tt.corrupt(repoPath); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := verify(repoPath); (err != nil) != tt.wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitCache_Add_corrupt(t *testing.T) {
+	remote, err := ioutil.TempDir("", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	writeRefsRepo(t, remote)
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	gc := NewGitCache(base)
+	if err := gc.Add("uno", "file://"+remote); err != nil {
+		t.Fatalf("GitCache.Add() error = %v", err)
+	}
+	// An interrupted fetch losing the objects
+	if err := os.RemoveAll(filepath.Join(base, "uno", "objects", "pack")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gc.Add("uno", "file://"+remote); err != nil {
+		t.Fatalf("GitCache.Add() error = %v", err)
+	}
+	if _, err := verify(filepath.Join(base, "uno")); err != nil {
+		t.Errorf("GitCache.Add() didn't clone again, verify() error = %v", err)
+	}
+	quarantined, err := ioutil.ReadDir(filepath.Join(base, quarantineDir))
+	if err != nil || len(quarantined) != 1 {
+		t.Errorf("GitCache.Add() quarantined = %v, %v, want 1 repo", quarantined, err)
+	}
+	// Quarantined repos are garbage
+	if _, _, err := gc.GC([]string{"uno"}); err != nil {
+		t.Fatalf("GitCache.GC() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, quarantineDir)); !os.IsNotExist(err) {
+		t.Errorf("GitCache.GC() left the quarantine, stat error = %v", err)
+	}
+}
+
+func TestGitCache_clone(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	gc := NewGitCache(base)
+	if err := gc.clone(filepath.Join("github.com", "unorepo", "uno"), "file:///nonexistent"); err == nil {
+		t.Errorf("GitCache.clone() error = nil, want an error")
+	}
+	// A failed clone leaves nothing behind
+	entries, err := ioutil.ReadDir(filepath.Join(base, "github.com", "unorepo"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("GitCache.clone() left %v, %v", entries, err)
+	}
+}
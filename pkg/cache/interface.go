@@ -5,13 +5,76 @@ import (
 	"golang.org/x/crypto/openpgp"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"time"
 )
 
 //Cache defines how all caching backends must behave.  Caches are required to return stats.
 type Cache interface {
 	Path() string
 	Add(repo, url string) (err error)
-	Stats(repo string) (commits int64, lines int64, err error)
+	// Index records another name of a cached repo, reusing a repo cached under that name
+	Index(repo, name string) (err error)
+	Stats(repo string, opts *StatsOptions) (stats *RepoStats, err error)
+	// Objects returns the number of objects of a cached repo
+	Objects(repo string) (objects int64, err error)
+	// Usage returns the disk usage of every cached repo
+	Usage() (usage []*RepoUsage, err error)
+	// GC removes the cached repos that aren't in keep, keeps the cache within its quota, and returns the usage of the
+	// repos left
+	GC(keep []string) (removed []string, usage []*RepoUsage, err error)
+}
+
+//RepoStats contains the totals of matched commits in a repo, and their time series
+type RepoStats struct {
+	Commits   int64
+	Additions int64
+	Deletions int64
+	// Lines is the number of lines changed, Additions plus Deletions
+	Lines int64
+	// Excluded is the number of lines changed in excluded files, which aren't part of Lines
+	Excluded int64
+	// Bots is the number of otherwise matched commits authored by bots, which aren't part of Commits
+	Bots      int64
+	Series    Series
+	Languages Languages
+	// Contributors are keyed by the identity of each commit's matched signature, its committer unless the Match
+	// policy says otherwise
+	Contributors Breakdown
+	// Domains are keyed by the domain of each commit's matched signature.  Commits only matched by member aren't part
+	// of any domain.
+	Domains Breakdown
+}
+
+//StatsOptions controls which commits of a cached repo are attributed to the organization.
+//A nil StatsOptions falls back to the "members" and "domains" config values.
+type StatsOptions struct {
+	// Members are the email addresses, including GitHub noreply addresses, whose commits are counted
+	Members []string
+	// Domains are the email domains whose commits are counted
+	Domains []string
+	// Match is the policy for which identity of a commit is matched against Members and Domains, either
+	// MatchCommitter (default), MatchAuthor or MatchEither
+	Match string
+	// Aliases maps lower case email addresses to the canonical identity of their owner, e.g. a GitHub login
+	Aliases map[string]string
+	// Since and Until limit the commits counted to a time window, zero values are unbounded.  Until is exclusive.
+	Since time.Time
+	Until time.Time
+	// CommitterDate applies the window, and time series, to the committer date rather than the author date
+	CommitterDate bool
+	// Bucket is the period of the time series, either BucketMonth (default) or BucketWeek
+	Bucket string
+	// Include and Exclude are path patterns of the files whose lines count, see DefaultExclude
+	Include []string
+	Exclude []string
+	// Merges is the policy for merge commits, either MergesSkip (default) or MergesCount
+	Merges string
+	// Reverts is the policy for revert commits, either RevertsExclude (default), RevertsNet or RevertsCount
+	Reverts string
+	// Bots are patterns of the names and email addresses of bots, whose commits are only counted as RepoStats.Bots
+	Bots []string
+	// Refs select the branches and tags whose history is analysed, see selectRefs.  None select the default branch.
+	Refs []string
 }
 
 //CommitIface is interface for Commits since go-git doesn't provide an interface.
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"path"
+	"strings"
+)
+
+// LanguageOther is the language of files that aren't recognised
+const LanguageOther = "Other"
+
+// extensionLanguages maps lower case file extensions to their language
+var extensionLanguages = map[string]string{
+	".go":         "Go",
+	".c":          "C",
+	".h":          "C",
+	".cc":         "C++",
+	".cpp":        "C++",
+	".cxx":        "C++",
+	".hh":         "C++",
+	".hpp":        "C++",
+	".hxx":        "C++",
+	".cs":         "C#",
+	".java":       "Java",
+	".kt":         "Kotlin",
+	".kts":        "Kotlin",
+	".scala":      "Scala",
+	".groovy":     "Groovy",
+	".gradle":     "Groovy",
+	".py":         "Python",
+	".pyx":        "Python",
+	".rb":         "Ruby",
+	".rs":         "Rust",
+	".swift":      "Swift",
+	".m":          "Objective-C",
+	".mm":         "Objective-C++",
+	".js":         "JavaScript",
+	".jsx":        "JavaScript",
+	".mjs":        "JavaScript",
+	".ts":         "TypeScript",
+	".tsx":        "TypeScript",
+	".php":        "PHP",
+	".pl":         "Perl",
+	".pm":         "Perl",
+	".lua":        "Lua",
+	".r":          "R",
+	".erl":        "Erlang",
+	".ex":         "Elixir",
+	".exs":        "Elixir",
+	".hs":         "Haskell",
+	".clj":        "Clojure",
+	".sh":         "Shell",
+	".bash":       "Shell",
+	".zsh":        "Shell",
+	".ps1":        "PowerShell",
+	".bat":        "Batchfile",
+	".cmd":        "Batchfile",
+	".asm":        "Assembly",
+	".s":          "Assembly",
+	".sql":        "SQL",
+	".proto":      "Protocol Buffer",
+	".html":       "HTML",
+	".htm":        "HTML",
+	".css":        "CSS",
+	".scss":       "SCSS",
+	".less":       "Less",
+	".vue":        "Vue",
+	".xml":        "XML",
+	".json":       "JSON",
+	".yaml":       "YAML",
+	".yml":        "YAML",
+	".toml":       "TOML",
+	".ini":        "INI",
+	".md":         "Markdown",
+	".markdown":   "Markdown",
+	".rst":        "reStructuredText",
+	".txt":        "Text",
+	".tex":        "TeX",
+	".tf":         "HCL",
+	".hcl":        "HCL",
+	".cmake":      "CMake",
+	".mk":         "Makefile",
+	".dockerfile": "Dockerfile",
+	".tpl":        "Go Template",
+	".tmpl":       "Go Template",
+}
+
+// filenameLanguages maps well known file names, without a telling extension, to their language
+var filenameLanguages = map[string]string{
+	"makefile":       "Makefile",
+	"gnumakefile":    "Makefile",
+	"dockerfile":     "Dockerfile",
+	"cmakelists.txt": "CMake",
+	"jenkinsfile":    "Groovy",
+	"rakefile":       "Ruby",
+	"gemfile":        "Ruby",
+	"vagrantfile":    "Ruby",
+	"go.mod":         "Go Module",
+	"build":          "Starlark",
+	"build.bazel":    "Starlark",
+	"workspace":      "Starlark",
+	"license":        "Text",
+	"copying":        "Text",
+}
+
+//language classifies a file by its linguist-language attribute, its name, or its extension, in that order
+func language(p string, attrs map[string]string) string {
+	if l := attrs["linguist-language"]; l != "" && !isSet(l) && l != "false" {
+		return l
+	}
+	base := strings.ToLower(path.Base(p))
+	if l, ok := filenameLanguages[base]; ok {
+		return l
+	}
+	// Dockerfile.test and similar
+	if strings.HasPrefix(base, "dockerfile.") {
+		return "Dockerfile"
+	}
+	if l, ok := extensionLanguages[path.Ext(base)]; ok {
+		return l
+	}
+	return LanguageOther
+}
+
+//LanguageStats are the lines changed in the files of a language
+type LanguageStats struct {
+	Additions int64 `json:"additions"`
+	Deletions int64 `json:"deletions"`
+}
+
+//Languages maps language names to their LanguageStats
+type Languages map[string]*LanguageStats
+
+//Add adds lines to a language and returns the resulting Languages, allocating them if nil
+func (l Languages) Add(language string, additions, deletions int64) Languages {
+	if l == nil {
+		l = make(Languages)
+	}
+	ls, ok := l[language]
+	if !ok {
+		ls = &LanguageStats{}
+		l[language] = ls
+	}
+	ls.Additions = ls.Additions + additions
+	ls.Deletions = ls.Deletions + deletions
+	return l
+}
+
+//Merge adds every language of o and returns the result.  o is left untouched.
+func (l Languages) Merge(o Languages) Languages {
+	for language, ls := range o {
+		l = l.Add(language, ls.Additions, ls.Deletions)
+	}
+	return l
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_language(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		attrs map[string]string
+		want  string
+	}{
+		{
+			name: "Extension",
+			path: "pkg/cache/gitcache.go",
+			want: "Go",
+		}, {
+			name: "Extension Case",
+			path: "src/Main.C",
+			want: "C",
+		}, {
+			name: "Filename",
+			path: "Makefile",
+			want: "Makefile",
+		}, {
+			name: "Dockerfile Suffix",
+			path: "Dockerfile.test",
+			want: "Dockerfile",
+		}, {
+			name: "Unknown",
+			path: "data.bin2",
+			want: LanguageOther,
+		}, {
+			name:  "Override",
+			path:  "include/foo.h",
+			attrs: map[string]string{"linguist-language": "C++"},
+			want:  "C++",
+		}, {
+			name:  "Override Unset",
+			path:  "include/foo.h",
+			attrs: map[string]string{"linguist-language": "false"},
+			want:  "C",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := language(tt.path, tt.attrs); got != tt.want {
+				t.Errorf("language() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguages_Merge(t *testing.T) {
+	var l Languages
+	l = l.Add("Go", 10, 2)
+	o := Languages{}.Add("Go", 1, 1).Add("C", 5, 0)
+	want := Languages{
+		"Go": {Additions: 11, Deletions: 3},
+		"C":  {Additions: 5, Deletions: 0},
+	}
+	if got := l.Merge(o); !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages.Merge() = %v, want %v", got, want)
+	}
+	if o["Go"].Additions != 1 {
+		t.Errorf("Languages.Merge() modified its argument")
+	}
+}
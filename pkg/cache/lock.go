@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// lockDir is the directory of the cache that holds the lock files of repos
+const lockDir = ".locks"
+
+// slowLock is the lock wait above which it's logged as info rather than debug
+const slowLock = time.Second
+
+//lock locks a cached repo against concurrent use, both within this process and across processes sharing the cache,
+//and returns the function that unlocks it.  Exclusive locks are for changing the repo, shared ones for reading it.
+func (gc *GitCache) lock(repo string, exclusive bool) (unlock func(), err error) {
+	repo = filepath.Clean(repo)
+	start := time.Now()
+	mu := gc.repoMutex(repo)
+	if exclusive {
+		mu.Lock()
+	} else {
+		mu.RLock()
+	}
+	release := func() {
+		if exclusive {
+			mu.Unlock()
+		} else {
+			mu.RUnlock()
+		}
+	}
+	var f *os.File
+	if f, err = lockFile(gc.lockPath(repo), exclusive); err != nil {
+		release()
+		return nil, errors.Wrap(err, "lock "+repo)
+	}
+	if wait := time.Since(start); wait > slowLock {
+		logrus.Infof("Waited %v for the lock of %v", wait, repo)
+	} else {
+		logrus.Debugf("Waited %v for the lock of %v", wait, repo)
+	}
+	return func() {
+		if err := funlock(f); err != nil {
+			logrus.Warnf("Failed to unlock %v: %s", repo, err)
+		}
+		f.Close()
+		release()
+	}, nil
+}
+
+//lockFile opens and locks a lock file.  A lock file removed meanwhile, along with its repo, is opened and locked
+//again, as holding the lock of the removed file wouldn't exclude anyone.
+func lockFile(lockPath string, exclusive bool) (f *os.File, err error) {
+	for {
+		if err = os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+			return
+		}
+		if f, err = os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return
+		}
+		if err = flock(f, exclusive); err != nil {
+			f.Close()
+			return nil, err
+		}
+		var locked, current os.FileInfo
+		if locked, err = f.Stat(); err != nil {
+			funlock(f)
+			f.Close()
+			return nil, err
+		}
+		if current, err = os.Stat(lockPath); err == nil && os.SameFile(locked, current) {
+			return f, nil
+		}
+		funlock(f)
+		f.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+}
+
+//lockPath returns the path of the lock file of a repo
+func (gc *GitCache) lockPath(repo string) string {
+	return filepath.Join(gc.Path(), lockDir, repo+".lock")
+}
+
+//removeLock deletes the lock file of a repo, which the caller holds, and its parent directories once empty
+func (gc *GitCache) removeLock(repo string) {
+	lockPath := gc.lockPath(repo)
+	if err := os.Remove(lockPath); err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Debugf("Failed to remove the lock of %v: %s", repo, err)
+		}
+		return
+	}
+	removeEmptyDirs(filepath.Dir(lockPath), filepath.Join(gc.Path(), lockDir))
+}
+
+//locked calls fn for a repo while holding its exclusive lock
+func (gc *GitCache) locked(repo string, fn func(repo string) error) (err error) {
+	var unlock func()
+	if unlock, err = gc.lock(repo, true); err != nil {
+		return
+	}
+	defer unlock()
+	return fn(repo)
+}
+
+//repoMutex returns the in-process lock of a cached repo
+func (gc *GitCache) repoMutex(repo string) *sync.RWMutex {
+	gc.locksMu.Lock()
+	defer gc.locksMu.Unlock()
+	if gc.locks == nil {
+		gc.locks = make(map[string]*sync.RWMutex)
+	}
+	mu, ok := gc.locks[repo]
+	if !ok {
+		mu = &sync.RWMutex{}
+		gc.locks[repo] = mu
+	}
+	return mu
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGitCache_lock(t *testing.T) {
+	tests := []struct {
+		name       string
+		exclusive  bool
+		other      bool
+		sameCache  bool
+		wantBlocks bool
+	}{
+		{
+			name:       "Exclusive In Process",
+			exclusive:  true,
+			sameCache:  true,
+			wantBlocks: true,
+		}, {
+			name:       "Exclusive Across Processes",
+			exclusive:  true,
+			wantBlocks: true,
+		}, {
+			name:       "Shared Exclusive Across Processes",
+			other:      true,
+			wantBlocks: true,
+		}, {
+			name:      "Shared",
+			sameCache: true,
+		}, {
+			name: "Shared Across Processes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ioutil.TempDir("", "cache")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(base)
+			gc := NewGitCache(base)
+			// Another process has its own GitCache, and only shares the lock files
+			other := NewGitCache(base)
+			if tt.sameCache {
+				other = gc
+			}
+			unlock, err := gc.lock("github.com/unorepo/uno", tt.exclusive)
+			if err != nil {
+				t.Fatalf("GitCache.lock() error = %v", err)
+			}
+			locked := make(chan func())
+			go func() {
+				unlock, err := other.lock("github.com/unorepo/uno", tt.other)
+				if err != nil {
+					t.Error(err)
+				}
+				locked <- unlock
+			}()
+			select {
+			case otherUnlock := <-locked:
+				if tt.wantBlocks {
+					t.Errorf("GitCache.lock() didn't block")
+				}
+				otherUnlock()
+				unlock()
+				return
+			case <-time.After(100 * time.Millisecond):
+				if !tt.wantBlocks {
+					t.Fatalf("GitCache.lock() blocked")
+				}
+			}
+			unlock()
+			select {
+			case otherUnlock := <-locked:
+				otherUnlock()
+			case <-time.After(time.Second):
+				t.Errorf("GitCache.lock() still blocked after unlocking")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+//flock takes an advisory lock on a file, blocking until it's available
+func flock(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+//funlock releases the advisory lock on a file
+func funlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
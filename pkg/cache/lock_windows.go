@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package cache
+
+import (
+	"os"
+)
+
+//flock is a no-op on Windows, so only the in-process locks apply
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
+
+//funlock is a no-op on Windows
+func funlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,202 @@
+package cache
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+//MemoryCachePath is the cache path in the config that selects a MemoryCache
+const MemoryCachePath = ":memory:"
+
+//MemoryCache keeps repos in memory rather than on disk, for one-shot runs and tests.  Everything is lost with it.
+type MemoryCache struct {
+	mu    sync.Mutex
+	repos map[string]*memoryRepo
+	names map[string]string
+	depth int
+}
+
+//memoryRepo is a repo of a MemoryCache, and the lock that guards it
+type memoryRepo struct {
+	mu   sync.RWMutex
+	rep  *git.Repository
+	used time.Time
+}
+
+//NewMemoryCache returns an empty MemoryCache, which clones and fetches as opts say.  The quota of opts is ignored.
+func NewMemoryCache(opts GitCacheOptions) *MemoryCache {
+	return &MemoryCache{
+		repos: make(map[string]*memoryRepo),
+		names: make(map[string]string),
+		depth: opts.Depth,
+	}
+}
+
+//Path returns MemoryCachePath, as there's no location on disk
+func (mc *MemoryCache) Path() string {
+	return MemoryCachePath
+}
+
+//Add clones a repo into memory, or fetches it when it's cached already
+func (mc *MemoryCache) Add(reponame, url string) (err error) {
+	reponame = filepath.Clean(reponame)
+	mc.mu.Lock()
+	mr, ok := mc.repos[reponame]
+	if !ok {
+		mr = &memoryRepo{}
+		mc.repos[reponame] = mr
+	}
+	mc.mu.Unlock()
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.used = time.Now()
+	if mr.rep == nil {
+		if mr.rep, err = git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
+			URL:        url,
+			RemoteName: "origin",
+			Depth:      mc.depth,
+		}); err != nil {
+			mc.mu.Lock()
+			mc.remove(reponame, mr)
+			mc.mu.Unlock()
+			return errors.Wrap(err, reponame)
+		}
+		logrus.Debugf("Cloned %v into memory", reponame)
+		return
+	}
+	if err = mr.rep.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		Force:      true,
+		Tags:       git.AllTags,
+		Depth:      mc.depth,
+	}); err != nil && err != git.NoErrAlreadyUpToDate {
+		return errors.Wrap(err, reponame)
+	}
+	return nil
+}
+
+//AddRepository caches an open repo as is, e.g. a fixture built in memory
+func (mc *MemoryCache) AddRepository(reponame string, rep *git.Repository) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.repos[filepath.Clean(reponame)] = &memoryRepo{rep: rep, used: time.Now()}
+}
+
+//Index records name as a name of a cached repo, and moves a repo cached under name to repo
+func (mc *MemoryCache) Index(repo, name string) (err error) {
+	repo, name = filepath.Clean(repo), filepath.Clean(name)
+	if repo == name {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.names[name] = repo
+	if mr, ok := mc.repos[name]; ok {
+		if _, ok := mc.repos[repo]; !ok {
+			mc.repos[repo] = mr
+			delete(mc.repos, name)
+		}
+	}
+	return
+}
+
+//Stats returns the stats of a cached repo, see GitCache.Stats
+func (mc *MemoryCache) Stats(reponame string, opts *StatsOptions) (stats *RepoStats, err error) {
+	mc.mu.Lock()
+	mr, ok := mc.repos[filepath.Clean(reponame)]
+	mc.mu.Unlock()
+	if !ok {
+		return nil, errors.Wrap(git.ErrRepositoryNotExists, reponame)
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	if mr.rep == nil {
+		return nil, errors.Wrap(git.ErrRepositoryNotExists, reponame)
+	}
+	return repoStats(mr.rep, reponame, opts)
+}
+
+//Usage returns the size of the objects of every cached repo, the largest first
+func (mc *MemoryCache) Usage() (usage []*RepoUsage, err error) {
+	// Repos are locked after releasing mc.mu, like Add does
+	mc.mu.Lock()
+	names := make(map[string][]string)
+	for name, repo := range mc.names {
+		names[repo] = append(names[repo], name)
+	}
+	repos := make(map[string]*memoryRepo, len(mc.repos))
+	for repo, mr := range mc.repos {
+		repos[repo] = mr
+	}
+	mc.mu.Unlock()
+	for repo, mr := range repos {
+		mr.mu.RLock()
+		ru := &RepoUsage{Repo: repo, Used: mr.used, Names: names[repo]}
+		if mr.rep != nil {
+			ru.Size, err = objectsSize(mr.rep)
+		}
+		mr.mu.RUnlock()
+		if err != nil {
+			return nil, errors.Wrap(err, repo)
+		}
+		sort.Strings(ru.Names)
+		usage = append(usage, ru)
+	}
+	sortUsage(usage)
+	return
+}
+
+//GC removes the cached repos that aren't in keep, a nil keep removes nothing, and returns the usage of the repos left
+func (mc *MemoryCache) GC(keep []string) (removed []string, usage []*RepoUsage, err error) {
+	if keep != nil {
+		kept := make(map[string]bool)
+		for _, repo := range keep {
+			kept[filepath.Clean(repo)] = true
+		}
+		mc.mu.Lock()
+		for repo, mr := range mc.repos {
+			if !kept[repo] {
+				mc.remove(repo, mr)
+				removed = append(removed, repo)
+			}
+		}
+		mc.mu.Unlock()
+		sort.Strings(removed)
+	}
+	usage, err = mc.Usage()
+	return
+}
+
+//remove removes a repo and its names, unless the repo was replaced meanwhile.  The caller holds mc.mu.
+func (mc *MemoryCache) remove(repo string, mr *memoryRepo) {
+	if mc.repos[repo] == mr {
+		delete(mc.repos, repo)
+	}
+	for name, r := range mc.names {
+		if r == repo {
+			delete(mc.names, name)
+		}
+	}
+}
+
+//objectsSize returns the total size of the objects of a repo
+func objectsSize(rep *git.Repository) (size int64, err error) {
+	var iter storer.EncodedObjectIter
+	if iter, err = rep.Storer.IterEncodedObjects(plumbing.AnyObject); err != nil {
+		return
+	}
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		size = size + obj.Size()
+		return nil
+	})
+	return
+}
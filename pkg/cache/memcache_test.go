@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+func TestMemoryCache(t *testing.T) {
+	remote, err := ioutil.TempDir("", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	writeRefsRepo(t, remote)
+	mc := NewMemoryCache(GitCacheOptions{})
+	opts := &StatsOptions{Domains: []string{"thalesesec.net"}}
+	// Adding twice clones, then fetches
+	for i := 0; i < 2; i++ {
+		if err := mc.Add("github.com/unorepo/uno", "file://"+remote); err != nil {
+			t.Fatalf("MemoryCache.Add() error = %v", err)
+		}
+	}
+	if got, err := mc.Stats("github.com/unorepo/uno", opts); err != nil || got.Commits != 1 {
+		t.Errorf("MemoryCache.Stats() = %+v, %v, want 1 commit", got, err)
+	}
+	if err := mc.Add("github.com/unorepo/dos", "file:///nonexistent"); err == nil {
+		t.Errorf("MemoryCache.Add() error = nil, want an error")
+	}
+	if _, err := mc.Stats("github.com/unorepo/dos", opts); err == nil {
+		t.Errorf("MemoryCache.Stats() error = nil, want an error for a failed clone")
+	}
+	// Indexing by ID moves the repo cached by name
+	if err := mc.Index("github.com/id/1234", "github.com/unorepo/uno"); err != nil {
+		t.Fatalf("MemoryCache.Index() error = %v", err)
+	}
+	if _, err := mc.Stats("github.com/id/1234", opts); err != nil {
+		t.Errorf("MemoryCache.Stats() error = %v", err)
+	}
+	usage, err := mc.Usage()
+	if err != nil || len(usage) != 1 {
+		t.Fatalf("MemoryCache.Usage() = %v, %v, want 1 repo", usage, err)
+	}
+	if usage[0].Repo != "github.com/id/1234" || usage[0].Size <= 0 || !reflect.DeepEqual(usage[0].Names, []string{"github.com/unorepo/uno"}) {
+		t.Errorf("MemoryCache.Usage() = %+v", usage[0])
+	}
+	removed, left, err := mc.GC([]string{})
+	if err != nil || !reflect.DeepEqual(removed, []string{"github.com/id/1234"}) || len(left) != 0 {
+		t.Errorf("MemoryCache.GC() = %v, %v, %v", removed, left, err)
+	}
+	if usage, err := mc.Usage(); err != nil || len(usage) != 0 {
+		t.Errorf("MemoryCache.Usage() = %v, %v, want none", usage, err)
+	}
+}
+
+func TestMemoryCache_AddRepository(t *testing.T) {
+	path, err := ioutil.TempDir("", "refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	writeRefsRepo(t, path)
+	rep, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := NewMemoryCache(GitCacheOptions{})
+	mc.AddRepository("fixture", rep)
+	got, err := mc.Stats("fixture", &StatsOptions{Domains: []string{"thalesesec.net"}, Refs: []string{RefsBranches, RefsTags}})
+	if err != nil || got.Commits != 4 {
+		t.Errorf("MemoryCache.Stats() = %+v, %v, want 4 commits", got, err)
+	}
+}
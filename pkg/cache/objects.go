@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+//idxHeader is the magic number and version 2 of pack index files, version 1 has no header
+var idxHeader = []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}
+
+//Objects returns the number of objects of a cached repo, without reading them, so it's cheap even for huge repos
+func (gc *GitCache) Objects(reponame string) (objects int64, err error) {
+	var unlock func()
+	if unlock, err = gc.lock(reponame, false); err != nil {
+		return
+	}
+	defer unlock()
+	repoPath := filepath.Join(gc.Path(), reponame)
+	if _, err := os.Stat(filepath.Join(repoPath, git.GitDirName)); err == nil {
+		repoPath = filepath.Join(repoPath, git.GitDirName)
+	}
+	if !isRepo(repoPath) {
+		return 0, errors.Wrap(git.ErrRepositoryNotExists, reponame)
+	}
+	if objects, err = countObjects(filepath.Join(repoPath, "objects")); err != nil {
+		err = errors.Wrap(err, reponame)
+	}
+	return
+}
+
+//countObjects returns the number of objects in an objects directory, from the fan-out tables of the pack indexes
+//and the number of loose objects.  Objects in several packs, or both packed and loose, are counted more than once.
+func countObjects(dir string) (objects int64, err error) {
+	var idxs []string
+	if idxs, err = filepath.Glob(filepath.Join(dir, "pack", "*.idx")); err != nil {
+		return
+	}
+	for _, idx := range idxs {
+		var n int64
+		if n, err = idxObjects(idx); err != nil {
+			return 0, errors.Wrap(err, filepath.Base(idx))
+		}
+		objects = objects + n
+	}
+	var fis []os.FileInfo
+	if fis, err = ioutil.ReadDir(dir); err != nil {
+		return
+	}
+	for _, fi := range fis {
+		// Loose objects are in directories named by the first two hex digits of their hash
+		if !fi.IsDir() || len(fi.Name()) != 2 || strings.Trim(fi.Name(), "0123456789abcdef") != "" {
+			continue
+		}
+		var loose []os.FileInfo
+		if loose, err = ioutil.ReadDir(filepath.Join(dir, fi.Name())); err != nil {
+			return
+		}
+		objects = objects + int64(len(loose))
+	}
+	return
+}
+
+//idxObjects returns the number of objects in a pack index, which is the last entry of its fan-out table
+func idxObjects(path string) (objects int64, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	header := make([]byte, len(idxHeader))
+	if _, err = io.ReadFull(f, header); err != nil {
+		return
+	}
+	offset := int64(0)
+	if bytes.Equal(header, idxHeader) {
+		offset = int64(len(idxHeader))
+	}
+	var count uint32
+	if _, err = f.Seek(offset+255*4, io.SeekStart); err != nil {
+		return
+	}
+	if err = binary.Read(f, binary.BigEndian, &count); err != nil {
+		return
+	}
+	return int64(count), nil
+}
+
+//Objects returns the number of objects of a cached repo
+func (mc *MemoryCache) Objects(reponame string) (objects int64, err error) {
+	mc.mu.Lock()
+	mr, ok := mc.repos[filepath.Clean(reponame)]
+	mc.mu.Unlock()
+	if !ok {
+		return 0, errors.Wrap(git.ErrRepositoryNotExists, reponame)
+	}
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	if mr.rep == nil {
+		return 0, errors.Wrap(git.ErrRepositoryNotExists, reponame)
+	}
+	if s, ok := mr.rep.Storer.(*memory.Storage); ok {
+		return int64(len(s.Objects)), nil
+	}
+	var iter storer.EncodedObjectIter
+	if iter, err = mr.rep.Storer.IterEncodedObjects(plumbing.AnyObject); err != nil {
+		return
+	}
+	err = iter.ForEach(func(plumbing.EncodedObject) error {
+		objects++
+		return nil
+	})
+	return
+}
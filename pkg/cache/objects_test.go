@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+//iterObjects counts the objects of a repo by reading every one of them
+func iterObjects(t *testing.T, rep *git.Repository) (objects int64) {
+	iter, err := rep.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = iter.ForEach(func(plumbing.EncodedObject) error {
+		objects++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestGitCache_Objects(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	writeRefsRepo(t, filepath.Join(base, "refs"))
+	rep, err := git.PlainOpen(filepath.Join(base, "refs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := iterObjects(t, rep)
+	gc := NewGitCache(base)
+	// Loose objects, then packed ones
+	for _, step := range []string{"Loose", "Packed"} {
+		if step == "Packed" {
+			if _, err = gc.compact("refs"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := gc.Objects("refs")
+		if err != nil {
+			t.Fatalf("%s: GitCache.Objects() error = %v", step, err)
+		}
+		if got != want {
+			t.Errorf("%s: GitCache.Objects() = %v, want %v", step, got, want)
+		}
+	}
+	if _, err = gc.Objects("missing"); err == nil {
+		t.Error("GitCache.Objects() of a missing repo didn't fail")
+	}
+}
+
+func TestMemoryCache_Objects(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	writeRefsRepo(t, base)
+	rep, err := git.PlainOpen(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := NewMemoryCache(GitCacheOptions{})
+	mc.AddRepository("refs", rep)
+	got, err := mc.Objects("refs")
+	if err != nil {
+		t.Fatalf("MemoryCache.Objects() error = %v", err)
+	}
+	if want := iterObjects(t, rep); got != want {
+		t.Errorf("MemoryCache.Objects() = %v, want %v", got, want)
+	}
+	if _, err = mc.Objects("missing"); err == nil {
+		t.Error("MemoryCache.Objects() of a missing repo didn't fail")
+	}
+}
+
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/diff"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+//DefaultExclude are the path patterns of vendored, generated and lock files, which are excluded unless disabled
+var DefaultExclude = []string{
+	"**/vendor/**",
+	"**/node_modules/**",
+	"**/third_party/**",
+	"go.sum",
+	"Gopkg.lock",
+	"glide.lock",
+	"package-lock.json",
+	"yarn.lock",
+	"Cargo.lock",
+	"Gemfile.lock",
+	"composer.lock",
+	"poetry.lock",
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.min.js",
+	"*.min.css",
+}
+
+// attributesFile is the name of the git attributes file read from the root of each commit
+const attributesFile = ".gitattributes"
+
+//GlobRegexp compiles a path pattern into a regular expression, the glob dialect of every pattern in the config.  "*"
+//and "?" don't match "/", "**" matches any number of directories, and patterns without a "/" match at any depth, like
+//.gitignore and .gitattributes patterns.
+func GlobRegexp(pattern string) *regexp.Regexp {
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	// A trailing "/" only matches directories, which means everything beneath them
+	if strings.HasSuffix(pattern, "/") {
+		pattern = pattern + "**"
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i = i + 2
+		case strings.HasPrefix(pattern[i:], "/**") && i+3 == len(pattern):
+			re.WriteString("(/.*)?")
+			i = i + 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i = i + 1
+		case pattern[i] == '*':
+			re.WriteString("[^/]*")
+		case pattern[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String())
+}
+
+//globs is a list of compiled path patterns
+type globs []*regexp.Regexp
+
+func newGlobs(patterns []string) (g globs) {
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			g = append(g, GlobRegexp(pattern))
+		}
+	}
+	return
+}
+
+//match reports whether p matches any of the patterns
+func (g globs) match(p string) bool {
+	for _, re := range g {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+//pathFilter decides which files of a commit count towards its lines
+type pathFilter struct {
+	include globs
+	exclude globs
+}
+
+//newPathFilter returns a pathFilter for the include and exclude patterns.  When there are include patterns only
+//matching files count, and exclude patterns always take precedence.
+func newPathFilter(include, exclude []string) *pathFilter {
+	return &pathFilter{
+		include: newGlobs(include),
+		exclude: newGlobs(exclude),
+	}
+}
+
+//excluded reports whether a file is excluded by the patterns, or marked as generated or vendored in its attributes
+func (f *pathFilter) excluded(p string, a map[string]string) bool {
+	if f == nil {
+		return false
+	}
+	if len(f.include) > 0 && !f.include.match(p) {
+		return true
+	}
+	if f.exclude.match(p) {
+		return true
+	}
+	return isSet(a["linguist-generated"]) || isSet(a["linguist-vendored"])
+}
+
+//attributeRule is a single line of a .gitattributes file
+type attributeRule struct {
+	pattern *regexp.Regexp
+	attrs   map[string]string
+}
+
+//attributes are the rules of a .gitattributes file, in order
+type attributes []attributeRule
+
+//parseAttributes parses the content of a .gitattributes file.  Set attributes have the value "true", unset ones
+//(prefixed with "-") have the value "false", and "!" prefixed ones are removed.
+func parseAttributes(content string) (attrs attributes) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := attributeRule{
+			pattern: GlobRegexp(fields[0]),
+			attrs:   make(map[string]string),
+		}
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "-"):
+				rule.attrs[field[1:]] = "false"
+			case strings.HasPrefix(field, "!"):
+				rule.attrs[field[1:]] = ""
+			case strings.Contains(field, "="):
+				kv := strings.SplitN(field, "=", 2)
+				rule.attrs[kv[0]] = kv[1]
+			default:
+				rule.attrs[field] = "true"
+			}
+		}
+		attrs = append(attrs, rule)
+	}
+	return
+}
+
+//lookup returns the attributes of a path, later rules override earlier ones
+func (attrs attributes) lookup(p string) (a map[string]string) {
+	a = make(map[string]string)
+	for _, rule := range attrs {
+		if !rule.pattern.MatchString(p) {
+			continue
+		}
+		for k, v := range rule.attrs {
+			if v == "" {
+				delete(a, k)
+			} else {
+				a[k] = v
+			}
+		}
+	}
+	return
+}
+
+//isSet reports whether an attribute value means true
+func isSet(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+//patchPath returns the path of a file patch, the new path unless the file was deleted
+func patchPath(p diff.FilePatch) string {
+	from, to := p.Files()
+	if to != nil {
+		return to.Path()
+	}
+	if from != nil {
+		return from.Path()
+	}
+	return ""
+}
+
+//readAttributes reads the .gitattributes file at the root of a tree, a missing file has no attributes
+func readAttributes(tree *object.Tree) (attrs attributes) {
+	f, err := tree.File(attributesFile)
+	if err != nil {
+		return
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return
+	}
+	return parseAttributes(content)
+}
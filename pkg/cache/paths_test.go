@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_globRegexp(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{
+			name:    "Basename Root",
+			pattern: "go.sum",
+			path:    "go.sum",
+			want:    true,
+		}, {
+			name:    "Basename Nested",
+			pattern: "go.sum",
+			path:    "tools/go.sum",
+			want:    true,
+		}, {
+			name:    "Basename Partial",
+			pattern: "go.sum",
+			path:    "ago.sum",
+			want:    false,
+		}, {
+			name:    "Basename Anchored",
+			pattern: "/go.sum",
+			path:    "tools/go.sum",
+			want:    false,
+		}, {
+			name:    "Star",
+			pattern: "*.pb.go",
+			path:    "api/v1/service.pb.go",
+			want:    true,
+		}, {
+			name:    "Anchored",
+			pattern: "docs/*.md",
+			path:    "docs/index.md",
+			want:    true,
+		}, {
+			name:    "Anchored Nested",
+			pattern: "docs/*.md",
+			path:    "docs/api/index.md",
+			want:    false,
+		}, {
+			name:    "Double Star",
+			pattern: "**/vendor/**",
+			path:    "cmd/tool/vendor/github.com/pkg/errors/errors.go",
+			want:    true,
+		}, {
+			name:    "Double Star Root",
+			pattern: "**/vendor/**",
+			path:    "vendor/modules.txt",
+			want:    true,
+		}, {
+			name:    "Double Star Other",
+			pattern: "**/vendor/**",
+			path:    "vendors.go",
+			want:    false,
+		}, {
+			name:    "Directory",
+			pattern: "generated/",
+			path:    "pkg/generated/types.go",
+			want:    true,
+		}, {
+			name:    "Question Mark",
+			pattern: "file?.txt",
+			path:    "file1.txt",
+			want:    true,
+		}, {
+			name:    "Meta Characters",
+			pattern: "a+b.txt",
+			path:    "aab.txt",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GlobRegexp(tt.pattern).MatchString(tt.path); got != tt.want {
+				t.Errorf("GlobRegexp(%q).MatchString(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pathFilter_excluded(t *testing.T) {
+	attrs := parseAttributes(`# comment
+*.gen.go linguist-generated
+third/** linguist-vendored=true
+third/ours/** -linguist-vendored
+`)
+	tests := []struct {
+		name   string
+		filter *pathFilter
+		path   string
+		want   bool
+	}{
+		{
+			name:   "Nil",
+			filter: nil,
+			path:   "go.sum",
+			want:   false,
+		}, {
+			name:   "Default",
+			filter: newPathFilter(nil, DefaultExclude),
+			path:   "go.sum",
+			want:   true,
+		}, {
+			name:   "Source",
+			filter: newPathFilter(nil, DefaultExclude),
+			path:   "main.go",
+			want:   false,
+		}, {
+			name:   "Not Included",
+			filter: newPathFilter([]string{"src/**"}, nil),
+			path:   "docs/index.md",
+			want:   true,
+		}, {
+			name:   "Included",
+			filter: newPathFilter([]string{"src/**"}, nil),
+			path:   "src/main.c",
+			want:   false,
+		}, {
+			name:   "Included Excluded",
+			filter: newPathFilter([]string{"src/**"}, []string{"*.pb.go"}),
+			path:   "src/api.pb.go",
+			want:   true,
+		}, {
+			name:   "Generated",
+			filter: newPathFilter(nil, nil),
+			path:   "pkg/types.gen.go",
+			want:   true,
+		}, {
+			name:   "Vendored",
+			filter: newPathFilter(nil, nil),
+			path:   "third/lib/lib.c",
+			want:   true,
+		}, {
+			name:   "Vendored Unset",
+			filter: newPathFilter(nil, nil),
+			path:   "third/ours/lib.c",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.excluded(tt.path, attrs.lookup(tt.path)); got != tt.want {
+				t.Errorf("pathFilter.excluded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_attributes_lookup(t *testing.T) {
+	attrs := parseAttributes(`*.go text eol=lf
+*.pb.go linguist-generated !text
+`)
+	tests := []struct {
+		name string
+		path string
+		want map[string]string
+	}{
+		{
+			name: "Single",
+			path: "main.go",
+			want: map[string]string{"text": "true", "eol": "lf"},
+		}, {
+			name: "Override",
+			path: "api/api.pb.go",
+			want: map[string]string{"eol": "lf", "linguist-generated": "true"},
+		}, {
+			name: "None",
+			path: "README.md",
+			want: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attrs.lookup(tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attributes.lookup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
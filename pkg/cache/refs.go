@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+const (
+	// RefsDefault selects the default branch of the remote
+	RefsDefault = "default"
+	// RefsBranches selects every branch
+	RefsBranches = "branches"
+	// RefsTags selects every tag
+	RefsTags = "tags"
+)
+
+const (
+	remotePrefix = "refs/remotes/origin/"
+	headsPrefix  = "refs/heads/"
+	tagsPrefix   = "refs/tags/"
+)
+
+//selectRefs returns the commits that the ref selectors point to, without duplicates.  A selector is RefsDefault,
+//RefsBranches, RefsTags, or a path pattern matched against the full ref names, e.g. "refs/tags/v*" or "release-*".
+//No selectors select the default branch.
+func selectRefs(rep *git.Repository, selectors []string) (hashes []plumbing.Hash, err error) {
+	if len(selectors) == 0 {
+		selectors = []string{RefsDefault}
+	}
+	var refs []*plumbing.Reference
+	var iter storer.ReferenceIter
+	if iter, err = rep.References(); err != nil {
+		return
+	}
+	if err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference && !strings.HasSuffix(ref.Name().String(), "/HEAD") {
+			refs = append(refs, ref)
+		}
+		return nil
+	}); err != nil {
+		return
+	}
+	seen := make(map[plumbing.Hash]bool)
+	add := func(hash plumbing.Hash) {
+		// Tags may point to annotated tag objects rather than commits
+		if tag, err := rep.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return
+			}
+			hash = commit.Hash
+		}
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+	for _, selector := range selectors {
+		switch strings.ToLower(selector) {
+		case RefsDefault:
+			var hash plumbing.Hash
+			if hash, err = defaultBranch(rep); err != nil {
+				return
+			}
+			add(hash)
+		case RefsBranches:
+			for _, ref := range refs {
+				if name := ref.Name().String(); strings.HasPrefix(name, remotePrefix) || strings.HasPrefix(name, headsPrefix) {
+					add(ref.Hash())
+				}
+			}
+		case RefsTags:
+			for _, ref := range refs {
+				if strings.HasPrefix(ref.Name().String(), tagsPrefix) {
+					add(ref.Hash())
+				}
+			}
+		default:
+			pattern := GlobRegexp(selector)
+			for _, ref := range refs {
+				if pattern.MatchString(ref.Name().String()) {
+					add(ref.Hash())
+				}
+			}
+		}
+	}
+	return
+}
+
+//defaultBranch returns the commit of the remote's default branch, which is the one HEAD was cloned from.
+//The remote branch is preferred, as fetching doesn't update the local one.
+func defaultBranch(rep *git.Repository) (hash plumbing.Hash, err error) {
+	var head *plumbing.Reference
+	if head, err = rep.Reference(plumbing.HEAD, false); err != nil {
+		return
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		branch := strings.TrimPrefix(head.Target().String(), headsPrefix)
+		if remote, err := rep.Reference(plumbing.ReferenceName(remotePrefix+branch), true); err == nil {
+			return remote.Hash(), nil
+		}
+	}
+	if head, err = rep.Head(); err != nil {
+		return
+	}
+	return head.Hash(), nil
+}
+
+//walk calls fn for every commit reachable from the given commits, once each and in no particular order.
+//The shallow commits of a shallow clone are left out, as their parents, and so their changes, are unknown.
+func walk(rep *git.Repository, from []plumbing.Hash, fn func(*object.Commit) error) (err error) {
+	seen := make(map[plumbing.Hash]bool)
+	var hashes []plumbing.Hash
+	if hashes, err = rep.Storer.Shallow(); err != nil {
+		return
+	}
+	shallow := make(map[plumbing.Hash]bool)
+	for _, hash := range hashes {
+		shallow[hash] = true
+	}
+	stack := append([]plumbing.Hash{}, from...)
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		var commit *object.Commit
+		if commit, err = rep.CommitObject(hash); err != nil {
+			return
+		}
+		// Root commits may be shallow too, yet their changes are known
+		if shallow[hash] && commit.NumParents() > 0 {
+			continue
+		}
+		if err = fn(commit); err != nil {
+			return
+		}
+		stack = append(stack, commit.ParentHashes...)
+	}
+	return
+}
@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/thales-e-security/contribstats/pkg/testutil"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+//refsRepo are the commits of the repo written by writeRefsRepo
+type refsRepo struct {
+	master, feature, release, tagged plumbing.Hash
+}
+
+//writeRefsRepo writes a repo with a master branch, a remote feature and release branch forked from it,
+//and an annotated tag on the release branch.  Every commit is by a member.
+func writeRefsRepo(t *testing.T, path string) (r refsRepo) {
+	repo := testutil.NewDiskRepo(t, path)
+	commit := func(name string, parents ...plumbing.Hash) plumbing.Hash {
+		return repo.Commit(testutil.Commit{
+			Author:  testutil.Sig("Jane", "jane@thalesesec.net"),
+			Message: "Add " + name,
+			Files:   map[string]string{name: name + "\n"},
+			Parents: parents,
+		})
+	}
+	r.master = commit("master")
+	r.feature = commit("feature", r.master)
+	repo.SetRef("refs/remotes/origin/feature", r.feature)
+	r.release = commit("release", r.master)
+	repo.SetRef("refs/remotes/origin/release-1.0", r.release)
+	r.tagged = commit("tagged", r.release)
+	repo.SetRef("refs/heads/master", r.master)
+	repo.Tag("v1.0", r.tagged, "Release v1.0\n")
+	return
+}
+
+func Test_selectRefs(t *testing.T) {
+	path, err := ioutil.TempDir("", "refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	r := writeRefsRepo(t, path)
+	rep, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		selectors []string
+		want      []plumbing.Hash
+	}{
+		{
+			name: "Default",
+			want: []plumbing.Hash{r.master},
+		}, {
+			name:      "Branches",
+			selectors: []string{"branches"},
+			want:      []plumbing.Hash{r.master, r.feature, r.release},
+		}, {
+			name:      "Tags",
+			selectors: []string{"tags"},
+			want:      []plumbing.Hash{r.tagged},
+		}, {
+			name:      "Pattern",
+			selectors: []string{"release-*"},
+			want:      []plumbing.Hash{r.release},
+		}, {
+			name:      "Duplicates",
+			selectors: []string{"default", "refs/heads/*", "tags", "refs/tags/v*"},
+			want:      []plumbing.Hash{r.master, r.tagged},
+		}, {
+			name:      "None",
+			selectors: []string{"refs/tags/v2*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectRefs(rep, tt.selectors)
+			if err != nil {
+				t.Fatalf("selectRefs() error = %v", err)
+			}
+			if !sameHashes(got, tt.want) {
+				t.Errorf("selectRefs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_defaultBranch(t *testing.T) {
+	path, err := ioutil.TempDir("", "refs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	r := writeRefsRepo(t, path)
+	rep, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := defaultBranch(rep); err != nil || got != r.master {
+		t.Errorf("defaultBranch() = %v, %v, want %v", got, err, r.master)
+	}
+	// Fetches only update the remote branch
+	if err := rep.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/master", r.feature)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := defaultBranch(rep); err != nil || got != r.feature {
+		t.Errorf("defaultBranch() = %v, %v, want %v", got, err, r.feature)
+	}
+}
+
+func TestGitCache_Stats_refs(t *testing.T) {
+	base, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+	writeRefsRepo(t, filepath.Join(base, "refs"))
+	gc := NewGitCache(base)
+	tests := []struct {
+		name        string
+		refs        []string
+		wantCommits int64
+	}{
+		{
+			name:        "Default",
+			wantCommits: 1,
+		}, {
+			name:        "Branches",
+			refs:        []string{"branches"},
+			wantCommits: 3,
+		}, {
+			name:        "Branches And Tags",
+			refs:        []string{"branches", "tags"},
+			wantCommits: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gc.Stats("refs", &StatsOptions{Domains: []string{"thalesesec.net"}, Refs: tt.refs})
+			if err != nil {
+				t.Fatalf("GitCache.Stats() error = %v", err)
+			}
+			if got.Commits != tt.wantCommits {
+				t.Errorf("GitCache.Stats() commits = %v, want %v", got.Commits, tt.wantCommits)
+			}
+		})
+	}
+}
+
+//sameHashes reports whether two lists hold the same hashes, in any order
+func sameHashes(a, b []plumbing.Hash) bool {
+	set := func(hashes []plumbing.Hash) map[plumbing.Hash]bool {
+		m := make(map[plumbing.Hash]bool)
+		for _, h := range hashes {
+			m[h] = true
+		}
+		return m
+	}
+	return len(a) == len(b) && reflect.DeepEqual(set(a), set(b))
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	// MergesSkip ignores merge commits entirely
+	MergesSkip = "skip"
+	// MergesCount counts merge commits, but none of their lines
+	MergesCount = "count"
+)
+
+const (
+	// RevertsExclude ignores revert commits, the reverted commits still count
+	RevertsExclude = "exclude"
+	// RevertsNet ignores both revert commits and the commits they revert, unless the revert was reverted too
+	RevertsNet = "net"
+	// RevertsCount counts revert commits like any other commit
+	RevertsCount = "count"
+)
+
+// revertRegexp matches the message "git revert" adds to the body of a revert commit
+var revertRegexp = regexp.MustCompile(`This reverts commit ([0-9a-f]{40})`)
+
+//isRevert reports whether a commit was made by "git revert", by its subject or body
+func isRevert(commit *object.Commit) bool {
+	return strings.HasPrefix(commit.Message, `Revert "`) || revertRegexp.MatchString(commit.Message)
+}
+
+//revertsOf returns the hashes of the commits a commit reverts
+func revertsOf(commit *object.Commit) (hashes []plumbing.Hash) {
+	for _, m := range revertRegexp.FindAllStringSubmatch(commit.Message, -1) {
+		hashes = append(hashes, plumbing.NewHash(m[1]))
+	}
+	return
+}
+
+//revertedCommits returns the hashes of the commits whose changes were reverted in the history reachable from the given
+//commits.  A revert that was reverted itself doesn't count, so the commit it reverted is back, e.g. X stays when R
+//reverts X and R2 reverts R.
+func revertedCommits(rep *git.Repository, from []plumbing.Hash) (reverted map[plumbing.Hash]bool, err error) {
+	reverters := make(map[plumbing.Hash][]plumbing.Hash)
+	err = walk(rep, from, func(commit *object.Commit) error {
+		for _, hash := range revertsOf(commit) {
+			reverters[hash] = append(reverters[hash], commit.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	reverted = make(map[plumbing.Hash]bool, len(reverters))
+	for hash := range reverters {
+		resolveReverted(hash, reverters, reverted)
+	}
+	return
+}
+
+//resolveReverted reports whether a commit was reverted by any revert that wasn't reverted itself, recording the
+//answers for each commit of the chain in reverted
+func resolveReverted(hash plumbing.Hash, reverters map[plumbing.Hash][]plumbing.Hash, reverted map[plumbing.Hash]bool) bool {
+	if r, ok := reverted[hash]; ok {
+		return r
+	}
+	// Set before following the chain, so a message naming a later commit can't loop
+	reverted[hash] = false
+	for _, r := range reverters[hash] {
+		if !resolveReverted(r, reverters, reverted) {
+			reverted[hash] = true
+			break
+		}
+	}
+	return reverted[hash]
+}
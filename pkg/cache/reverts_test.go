@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/thales-e-security/contribstats/pkg/testutil"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func Test_isRevert(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    bool
+	}{
+		{
+			name:    "Subject",
+			message: "Revert \"Add foo\"\n",
+			want:    true,
+		}, {
+			name:    "Body",
+			message: "Undo foo\n\nThis reverts commit 0123456789abcdef0123456789abcdef01234567.\n",
+			want:    true,
+		}, {
+			name:    "Regular",
+			message: "Revert to the old behaviour of foo\n",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRevert(&object.Commit{Message: tt.message}); got != tt.want {
+				t.Errorf("isRevert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_revertsOf(t *testing.T) {
+	commit := &object.Commit{
+		Message: "Revert \"Add foo\"\n\nThis reverts commit 0123456789abcdef0123456789abcdef01234567.\n",
+	}
+	want := []plumbing.Hash{plumbing.NewHash("0123456789abcdef0123456789abcdef01234567")}
+	if got := revertsOf(commit); !reflect.DeepEqual(got, want) {
+		t.Errorf("revertsOf() = %v, want %v", got, want)
+	}
+}
+
+func TestGitCache_Stats_policies(t *testing.T) {
+	td, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(td)
+	gc := NewGitCache(td)
+	writeRevertsRepo(t, filepath.Join(td, "github.com/unorepo/reverts"))
+
+	tests := []struct {
+		name        string
+		merges      string
+		reverts     string
+		wantCommits int64
+		wantLines   int64
+	}{
+		{
+			// foo (3) and bar (2), the revert and the merge are ignored
+			name:        "Defaults",
+			wantCommits: 2,
+			wantLines:   5,
+		}, {
+			name:        "Count Merges",
+			merges:      MergesCount,
+			wantCommits: 3,
+			wantLines:   5,
+		}, {
+			// bar (2) only, foo was reverted
+			name:        "Net Reverts",
+			reverts:     RevertsNet,
+			wantCommits: 1,
+			wantLines:   2,
+		}, {
+			// foo (3), bar (2) and the revert of foo (3)
+			name:        "Count Reverts",
+			reverts:     RevertsCount,
+			wantCommits: 3,
+			wantLines:   8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gc.Stats("github.com/unorepo/reverts", &StatsOptions{
+				Domains: []string{"thalesesec.net"},
+				Merges:  tt.merges,
+				Reverts: tt.reverts,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Commits != tt.wantCommits {
+				t.Errorf("GitCache.Stats() commits = %v, want %v", got.Commits, tt.wantCommits)
+			}
+			if got.Lines != tt.wantLines {
+				t.Errorf("GitCache.Stats() lines = %v, want %v", got.Lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestGitCache_Stats_revertChain(t *testing.T) {
+	td, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(td)
+	gc := NewGitCache(td)
+	repo := testutil.NewDiskRepo(t, filepath.Join(td, "github.com/unorepo/chain"))
+	member := testutil.Sig("Jane", "jane@thalesesec.net")
+	foo := repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Add foo",
+		Files:   map[string]string{"foo": "1\n2\n3\n"},
+	})
+	revert := repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Revert \"Add foo\"\n\nThis reverts commit " + foo.String() + ".\n",
+		Delete:  []string{"foo"},
+		Parents: []plumbing.Hash{foo},
+	})
+	repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Revert \"Revert \"Add foo\"\"\n\nThis reverts commit " + revert.String() + ".\n",
+		Files:   map[string]string{"foo": "1\n2\n3\n"},
+		Parents: []plumbing.Hash{revert},
+	})
+	tests := []struct {
+		name        string
+		reverts     string
+		wantCommits int64
+		wantLines   int64
+	}{
+		// foo is back, as its revert was reverted
+		{name: "Net Reverts", reverts: RevertsNet, wantCommits: 1, wantLines: 3},
+		{name: "Exclude Reverts", reverts: RevertsExclude, wantCommits: 1, wantLines: 3},
+		{name: "Count Reverts", reverts: RevertsCount, wantCommits: 3, wantLines: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gc.Stats("github.com/unorepo/chain", &StatsOptions{Domains: []string{"thalesesec.net"}, Reverts: tt.reverts})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Commits != tt.wantCommits || got.Lines != tt.wantLines {
+				t.Errorf("GitCache.Stats() = %v commits, %v lines, want %v, %v", got.Commits, got.Lines, tt.wantCommits, tt.wantLines)
+			}
+		})
+	}
+}
+
+//writeRevertsRepo creates a repo with a commit, its revert, and a merge of a branch with another commit
+func writeRevertsRepo(t *testing.T, path string) {
+	repo := testutil.NewDiskRepo(t, path)
+	member := testutil.Sig("Jane", "jane@thalesesec.net")
+	base := repo.Commit(testutil.Commit{
+		Author:  testutil.Sig("Jane", "someone@example.com"),
+		Message: "Initial",
+		Files:   map[string]string{"README": "readme\n"},
+	})
+	foo := repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Add foo",
+		Files:   map[string]string{"foo": "1\n2\n3\n"},
+		Parents: []plumbing.Hash{base},
+	})
+	revert := repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Revert \"Add foo\"\n\nThis reverts commit " + foo.String() + ".\n",
+		Delete:  []string{"foo"},
+		Parents: []plumbing.Hash{foo},
+	})
+	bar := repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Add bar",
+		Files:   map[string]string{"bar": "1\n2\n"},
+		Parents: []plumbing.Hash{revert},
+	})
+	repo.Commit(testutil.Commit{
+		Author:  member,
+		Message: "Merge branch 'bar'",
+		Parents: []plumbing.Hash{revert, bar},
+	})
+}
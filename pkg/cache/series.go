@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// BucketMonth groups a Series by calendar month
+	BucketMonth = "month"
+	// BucketWeek groups a Series by ISO week, starting on Monday
+	BucketWeek = "week"
+)
+
+//Bucket aggregates the matched commits and lines of the period beginning at Start, counted like RepoStats
+type Bucket struct {
+	Start     time.Time `json:"start"`
+	Commits   int64     `json:"commits"`
+	Additions int64     `json:"additions"`
+	Deletions int64     `json:"deletions"`
+	Lines     int64     `json:"lines"`
+}
+
+//Series is a time series of Buckets, sorted by Start
+type Series []*Bucket
+
+//BucketStart returns the start of the week or month, in UTC, that t falls in.  Anything but BucketWeek is treated as BucketMonth.
+func BucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	if strings.ToLower(bucket) == BucketWeek {
+		// time.Weekday starts on Sunday, ISO weeks start on Monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+//Add adds the counts of b to the Bucket of the same Start, creating it if needed, and returns the resulting Series
+func (s Series) Add(b Bucket) Series {
+	for _, existing := range s {
+		if existing.Start.Equal(b.Start) {
+			existing.Commits = existing.Commits + b.Commits
+			existing.Additions = existing.Additions + b.Additions
+			existing.Deletions = existing.Deletions + b.Deletions
+			existing.Lines = existing.Additions + existing.Deletions
+			return s
+		}
+	}
+	b.Lines = b.Additions + b.Deletions
+	s = append(s, &b)
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Start.Before(s[j].Start)
+	})
+	return s
+}
+
+//Merge adds every Bucket of o to the Series and returns the result.  o is left untouched.
+func (s Series) Merge(o Series) Series {
+	for _, b := range o {
+		s = s.Add(*b)
+	}
+	return s
+}
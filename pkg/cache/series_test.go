@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketStart(t *testing.T) {
+	// A Thursday
+	when := time.Date(2018, 8, 9, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		t      time.Time
+		bucket string
+		want   time.Time
+	}{
+		{
+			name:   "Month",
+			t:      when,
+			bucket: BucketMonth,
+			want:   time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:   "Default",
+			t:      when,
+			bucket: "",
+			want:   time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:   "Week",
+			t:      when,
+			bucket: BucketWeek,
+			want:   time.Date(2018, 8, 6, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:   "Week Sunday",
+			t:      time.Date(2018, 8, 5, 23, 0, 0, 0, time.UTC),
+			bucket: BucketWeek,
+			want:   time.Date(2018, 7, 30, 0, 0, 0, 0, time.UTC),
+		}, {
+			name:   "Month Timezone",
+			t:      time.Date(2018, 9, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			bucket: BucketMonth,
+			want:   time.Date(2018, 8, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BucketStart(tt.t, tt.bucket); !got.Equal(tt.want) {
+				t.Errorf("BucketStart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeries_Merge(t *testing.T) {
+	jan := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+	s := Series{}.Add(Bucket{Start: mar, Commits: 1, Additions: 3}).Add(Bucket{Start: jan, Commits: 1, Additions: 1, Deletions: 1})
+	o := Series{{Start: feb, Commits: 2, Additions: 4, Lines: 4}, {Start: mar, Commits: 1, Deletions: 1, Lines: 1}}
+	want := Series{
+		{Start: jan, Commits: 1, Additions: 1, Deletions: 1, Lines: 2},
+		{Start: feb, Commits: 2, Additions: 4, Lines: 4},
+		{Start: mar, Commits: 2, Additions: 3, Deletions: 1, Lines: 4},
+	}
+	if got := s.Merge(o); !reflect.DeepEqual(got, want) {
+		t.Errorf("Series.Merge() = %v, want %v", got, want)
+	}
+	if o[1].Commits != 1 {
+		t.Errorf("Series.Merge() modified its argument")
+	}
+}
@@ -1,5 +1,12 @@
 package cache
 
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
 func stringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
@@ -8,3 +15,69 @@ func stringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+const (
+	// MatchCommitter counts the commits committed by a member or domain
+	MatchCommitter = "committer"
+	// MatchAuthor counts the commits authored by a member or domain
+	MatchAuthor = "author"
+	// MatchEither counts the commits either authored or committed by a member or domain
+	MatchEither = "either"
+)
+
+//matched returns the signature of a commit that belongs to one of the members or domains, as the Match policy says,
+//the author's first, or nil when neither does
+func (o *StatsOptions) matched(commit *object.Commit) *object.Signature {
+	author := o.Match == MatchAuthor || o.Match == MatchEither
+	committer := o.Match != MatchAuthor
+	switch {
+	case author && o.matches(commit.Author.Email):
+		return &commit.Author
+	case committer && o.matches(commit.Committer.Email):
+		return &commit.Committer
+	}
+	return nil
+}
+
+//matches reports whether an email address belongs to one of the members or domains, ignoring case
+func (o *StatsOptions) matches(email string) bool {
+	email = strings.ToLower(email)
+	for _, member := range o.Members {
+		if strings.ToLower(member) == email {
+			return true
+		}
+	}
+	return o.domain(email) != ""
+}
+
+//when returns the author, or committer, date of a commit
+func (o *StatsOptions) when(commit *object.Commit) time.Time {
+	if o.CommitterDate {
+		return commit.Committer.When
+	}
+	return commit.Author.When
+}
+
+//inWindow reports whether the commit's author, or committer, date falls within the time window
+func (o *StatsOptions) inWindow(commit *object.Commit) bool {
+	when := o.when(commit)
+	if !o.Since.IsZero() && when.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !when.Before(o.Until) {
+		return false
+	}
+	return true
+}
+
+//countLines returns the number of lines in the content of a diff chunk, the last of which may not end in a newline
+func countLines(content string) (lines int64) {
+	if content == "" {
+		return
+	}
+	lines = int64(strings.Count(content, "\n"))
+	if !strings.HasSuffix(content, "\n") {
+		lines = lines + 1
+	}
+	return
+}
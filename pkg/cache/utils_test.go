@@ -1,6 +1,11 @@
 package cache
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
 
 func Test_stringInSlice(t *testing.T) {
 	type args struct {
@@ -39,3 +44,148 @@ func Test_stringInSlice(t *testing.T) {
 		})
 	}
 }
+
+func TestStatsOptions_matches(t *testing.T) {
+	opts := &StatsOptions{
+		Members: []string{"Jane@example.com", "1234+jdoe@users.noreply.github.com"},
+		Domains: []string{"thalesesec.net"},
+	}
+	tests := []struct {
+		name  string
+		email string
+		want  bool
+	}{
+		{
+			name:  "Member",
+			email: "jane@example.com",
+			want:  true,
+		}, {
+			name:  "Noreply",
+			email: "1234+jdoe@users.noreply.github.com",
+			want:  true,
+		}, {
+			name:  "Domain",
+			email: "someone@Thalesesec.net",
+			want:  true,
+		}, {
+			name:  "Other",
+			email: "someone@example.com",
+			want:  false,
+		}, {
+			name:  "Invalid",
+			email: "nobody",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opts.matches(tt.email); got != tt.want {
+				t.Errorf("StatsOptions.matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsOptions_matched(t *testing.T) {
+	member := object.Signature{Name: "Jane", Email: "jane@thalesesec.net"}
+	other := object.Signature{Name: "John", Email: "john@example.com"}
+	tests := []struct {
+		name      string
+		match     string
+		author    object.Signature
+		committer object.Signature
+		want      string
+	}{
+		{name: "Committer", author: other, committer: member, want: member.Email},
+		{name: "Committer Author", author: member, committer: other},
+		{name: "Author", match: MatchAuthor, author: member, committer: other, want: member.Email},
+		{name: "Author Committer", match: MatchAuthor, author: other, committer: member},
+		{name: "Either Author", match: MatchEither, author: member, committer: other, want: member.Email},
+		{name: "Either Committer", match: MatchEither, author: other, committer: member, want: member.Email},
+		{name: "Either Neither", match: MatchEither, author: other, committer: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &StatsOptions{Domains: []string{"thalesesec.net"}, Match: tt.match}
+			var got string
+			if sig := opts.matched(&object.Commit{Author: tt.author, Committer: tt.committer}); sig != nil {
+				got = sig.Email
+			}
+			if got != tt.want {
+				t.Errorf("StatsOptions.matched() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsOptions_inWindow(t *testing.T) {
+	since := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC)
+	commit := &object.Commit{
+		Author:    object.Signature{When: time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC)},
+		Committer: object.Signature{When: time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	tests := []struct {
+		name string
+		opts *StatsOptions
+		want bool
+	}{
+		{
+			name: "Unbounded",
+			opts: &StatsOptions{},
+			want: true,
+		}, {
+			name: "Author Before",
+			opts: &StatsOptions{Since: since, Until: until},
+			want: false,
+		}, {
+			name: "Committer Within",
+			opts: &StatsOptions{Since: since, Until: until, CommitterDate: true},
+			want: true,
+		}, {
+			name: "Committer After",
+			opts: &StatsOptions{Until: commit.Committer.When, CommitterDate: true},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.inWindow(commit); got != tt.want {
+				t.Errorf("StatsOptions.inWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_countLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int64
+	}{
+		{
+			name:    "Empty",
+			content: "",
+			want:    0,
+		}, {
+			name:    "Trailing Newline",
+			content: "a\nb\n",
+			want:    2,
+		}, {
+			name:    "No Trailing Newline",
+			content: "a\nb",
+			want:    2,
+		}, {
+			name:    "Blank Line",
+			content: "\n",
+			want:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countLines(tt.content); got != tt.want {
+				t.Errorf("countLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
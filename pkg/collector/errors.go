@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+//Stages of collecting a repo, at which it may fail
+const (
+	// StageClone is cloning or fetching the repo into the cache
+	StageClone = "clone"
+	// StageStats is computing the stats of the cached repo
+	StageStats = "stats"
+	// StageCollect is the collection as a whole, e.g. when it times out before the repo is done
+	StageCollect = "collect"
+)
+
+//Kinds of failures, so consumers can tell e.g. missing credentials from broken repos
+const (
+	// FailureClone is a repo that couldn't be cloned or fetched
+	FailureClone = "clone"
+	// FailureAuth is a repo that couldn't be accessed with the credentials given, if any
+	FailureAuth = "auth"
+	// FailureTimeout is a repo that took too long
+	FailureTimeout = "timeout"
+	// FailureStats is a cached repo whose stats couldn't be computed
+	FailureStats = "stats"
+)
+
+//errTimeout is the cause of the repos left unfinished when a collection times out
+var errTimeout = errors.New("Timed out")
+
+//RepoError is the failure to collect a repo at some stage, classified by kind
+type RepoError struct {
+	Repo  string
+	Stage string
+	Kind  string
+	Err   error
+}
+
+//newRepoError returns the failure of a repo at a stage, classifying the error
+func newRepoError(repo, stage string, err error) *RepoError {
+	return &RepoError{
+		Repo:  repo,
+		Stage: stage,
+		Kind:  failureKind(stage, err),
+		Err:   err,
+	}
+}
+
+func (e *RepoError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Repo, e.Stage, e.Err)
+}
+
+//Cause returns the underlying error, for errors.Cause
+func (e *RepoError) Cause() error {
+	return e.Err
+}
+
+//failureKind classifies the error of a stage
+func failureKind(stage string, err error) string {
+	cause := errors.Cause(err)
+	if cause == transport.ErrAuthenticationRequired || cause == transport.ErrAuthorizationFailed {
+		return FailureAuth
+	}
+	if resp, ok := cause.(*github.ErrorResponse); ok && resp.Response != nil &&
+		(resp.Response.StatusCode == http.StatusUnauthorized || resp.Response.StatusCode == http.StatusForbidden) {
+		return FailureAuth
+	}
+	if cause == errTimeout || cause == context.DeadlineExceeded {
+		return FailureTimeout
+	}
+	if ne, ok := cause.(net.Error); ok && ne.Timeout() {
+		return FailureTimeout
+	}
+	if stage == StageStats {
+		return FailureStats
+	}
+	return FailureClone
+}
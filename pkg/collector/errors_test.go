@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+func Test_failureKind(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage string
+		err   error
+		want  string
+	}{
+		{
+			name:  "Clone",
+			stage: StageClone,
+			err:   errors.New("repository not found"),
+			want:  FailureClone,
+		}, {
+			name:  "Auth",
+			stage: StageClone,
+			err:   errors.Wrap(transport.ErrAuthenticationRequired, "github.com/id/1"),
+			want:  FailureAuth,
+		}, {
+			name:  "Auth API",
+			stage: StageClone,
+			err:   &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}},
+			want:  FailureAuth,
+		}, {
+			name:  "Timeout",
+			stage: StageClone,
+			err:   errors.Wrap(context.DeadlineExceeded, "github.com/id/1"),
+			want:  FailureTimeout,
+		}, {
+			name:  "Timed Out",
+			stage: StageCollect,
+			err:   errTimeout,
+			want:  FailureTimeout,
+		}, {
+			name:  "Stats",
+			stage: StageStats,
+			err:   errors.New("reference not found"),
+			want:  FailureStats,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureKind(tt.stage, tt.err); got != tt.want {
+				t.Errorf("failureKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepoError_Error(t *testing.T) {
+	err := newRepoError("github.com/unorepo/uno", StageClone, transport.ErrAuthenticationRequired)
+	if want := "github.com/unorepo/uno: clone: authentication required"; err.Error() != want {
+		t.Errorf("RepoError.Error() = %v, want %v", err.Error(), want)
+	}
+	if errors.Cause(err) != transport.ErrAuthenticationRequired {
+		t.Errorf("errors.Cause() = %v, want %v", errors.Cause(err), transport.ErrAuthenticationRequired)
+	}
+}
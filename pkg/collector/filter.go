@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/thales-e-security/contribstats/pkg/cache"
+	"github.com/thales-e-security/contribstats/pkg/config"
+)
+
+//Visibilities of repos
+const (
+	VisibilityPublic   = "public"
+	VisibilityPrivate  = "private"
+	VisibilityInternal = "internal"
+)
+
+//orgRepo is a repo as listed by the API, with the fields go-github doesn't know about yet
+type orgRepo struct {
+	*github.Repository
+	Disabled   *bool   `json:"disabled,omitempty"`
+	IsTemplate *bool   `json:"is_template,omitempty"`
+	Visibility *string `json:"visibility,omitempty"`
+}
+
+//visibility returns the visibility of a repo, which older GitHub Enterprise servers only tell by whether it's private
+func (r *orgRepo) visibility() string {
+	if r.Visibility != nil {
+		return strings.ToLower(*r.Visibility)
+	}
+	if r.GetPrivate() {
+		return VisibilityPrivate
+	}
+	return VisibilityPublic
+}
+
+//namePattern matches the full names of repos.  Patterns are globs like the path patterns, which match the repo name
+//alone when they don't contain a "/", or regular expressions between slashes, e.g. "/^unorepo/linux-.*$/".
+type namePattern struct {
+	re *regexp.Regexp
+}
+
+func newNamePattern(pattern string) (p namePattern, err error) {
+	pattern = strings.TrimPrefix(pattern, "github.com/")
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		// Lowercasing would change the meaning of escapes such as \D, so regular expressions ignore case instead
+		p.re, err = regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+		return
+	}
+	p.re = cache.GlobRegexp(strings.ToLower(pattern))
+	return
+}
+
+func (p namePattern) match(repo *github.Repository) bool {
+	return p.re.MatchString(strings.ToLower(repo.GetFullName()))
+}
+
+//repoFilter selects the repos to collect, before they're cloned
+type repoFilter struct {
+	blacklist     []namePattern
+	allowlist     []namePattern
+	skipForks     bool
+	skipArchived  bool
+	skipDisabled  bool
+	skipTemplates bool
+	topics        []string
+	excludeTopics []string
+	visibility    string
+}
+
+//newRepoFilter returns the filter of the config, or an error for an invalid pattern or visibility
+func newRepoFilter(constants config.Config) (f *repoFilter, err error) {
+	f = &repoFilter{
+		skipForks:     constants.SkipForks,
+		skipArchived:  constants.SkipArchived,
+		skipDisabled:  constants.SkipDisabled,
+		skipTemplates: constants.SkipTemplates,
+		topics:        lowerAll(constants.Topics),
+		excludeTopics: lowerAll(constants.ExcludeTopics),
+		visibility:    strings.ToLower(constants.Visibility),
+	}
+	switch f.visibility {
+	case "", VisibilityPublic, VisibilityPrivate, VisibilityInternal:
+	default:
+		return nil, errors.Errorf("invalid visibility %q", constants.Visibility)
+	}
+	if f.blacklist, err = newNamePatterns(constants.Blacklist); err != nil {
+		return nil, errors.Wrap(err, "blacklist")
+	}
+	if f.allowlist, err = newNamePatterns(constants.Allowlist); err != nil {
+		return nil, errors.Wrap(err, "allowlist")
+	}
+	return
+}
+
+func newNamePatterns(patterns []string) (ps []namePattern, err error) {
+	for _, pattern := range patterns {
+		var p namePattern
+		if p, err = newNamePattern(pattern); err != nil {
+			return nil, errors.Wrap(err, pattern)
+		}
+		ps = append(ps, p)
+	}
+	return
+}
+
+//skip returns why a repo isn't collected, or empty when it is
+func (f *repoFilter) skip(repo *orgRepo) string {
+	switch {
+	case matchAny(f.blacklist, repo.Repository):
+		return "blacklisted"
+	case len(f.allowlist) > 0 && !matchAny(f.allowlist, repo.Repository):
+		return "not allowlisted"
+	case f.skipForks && repo.GetFork():
+		return "a fork"
+	case f.skipArchived && repo.GetArchived():
+		return "archived"
+	case f.skipDisabled && repo.Disabled != nil && *repo.Disabled:
+		return "disabled"
+	case f.skipTemplates && repo.IsTemplate != nil && *repo.IsTemplate:
+		return "a template"
+	case f.visibility != "" && repo.visibility() != f.visibility:
+		return repo.visibility()
+	case len(f.topics) > 0 && !anyTopic(repo.Topics, f.topics):
+		return "without a topic"
+	case anyTopic(repo.Topics, f.excludeTopics):
+		return "an excluded topic"
+	}
+	return ""
+}
+
+func matchAny(patterns []namePattern, repo *github.Repository) bool {
+	for _, p := range patterns {
+		if p.match(repo) {
+			return true
+		}
+	}
+	return false
+}
+
+//anyTopic returns whether any of a repo's topics is one of topics, which are lower case
+func anyTopic(repoTopics, topics []string) bool {
+	for _, topic := range repoTopics {
+		for _, t := range topics {
+			if strings.ToLower(topic) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lowerAll(s []string) (lower []string) {
+	for _, v := range s {
+		lower = append(lower, strings.ToLower(v))
+	}
+	return
+}
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/thales-e-security/contribstats/pkg/config"
+	"github.com/thales-e-security/contribstats/pkg/testutil"
+)
+
+func Test_repoFilter_skip(t *testing.T) {
+	uno := &orgRepo{Repository: testRepo("unorepo", "uno")}
+	kernel := &orgRepo{Repository: testRepo("unorepo", "linux-kernel")}
+	fork := &orgRepo{Repository: testRepo("unorepo", "fork")}
+	fork.Fork = github.Bool(true)
+	archived := &orgRepo{Repository: testRepo("unorepo", "archived")}
+	archived.Archived = github.Bool(true)
+	disabled := &orgRepo{Repository: testRepo("unorepo", "disabled"), Disabled: github.Bool(true)}
+	template := &orgRepo{Repository: testRepo("unorepo", "template"), IsTemplate: github.Bool(true)}
+	private := &orgRepo{Repository: testRepo("unorepo", "private")}
+	private.Private = github.Bool(true)
+	internal := &orgRepo{Repository: testRepo("unorepo", "internal"), Visibility: github.String("Internal")}
+	topical := &orgRepo{Repository: testRepo("unorepo", "topical")}
+	topical.Topics = []string{"Security", "go"}
+	all := []*orgRepo{uno, kernel, fork, archived, disabled, template, private, internal, topical}
+	names := func(repos ...*orgRepo) (names []string) {
+		for _, repo := range repos {
+			names = append(names, repo.GetName())
+		}
+		return
+	}
+	tests := []struct {
+		name      string
+		constants config.Config
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "None",
+			want: names(all...),
+		}, {
+			name:      "Blacklist",
+			constants: config.Config{Blacklist: []string{"github.com/unorepo/uno", "linux-*", "/^unorepo/(fork|archived)$/"}},
+			want:      names(disabled, template, private, internal, topical),
+		}, {
+			name:      "Allowlist",
+			constants: config.Config{Allowlist: []string{"unorepo/UNO", "*-kernel"}, Blacklist: []string{"linux-kernel"}},
+			want:      names(uno),
+		}, {
+			name:      "Regexp Escapes",
+			constants: config.Config{Allowlist: []string{`/^UNOREPO/\D+$/`}, Blacklist: []string{`/^unorepo/\S*-\S*$/`}},
+			want:      names(uno, fork, archived, disabled, template, private, internal, topical),
+		}, {
+			name:      "Any Depth",
+			constants: config.Config{Allowlist: []string{"**/f*", "unorepo/**/t*"}},
+			want:      names(fork, template, topical),
+		}, {
+			name:      "Glob Within Owner",
+			constants: config.Config{Blacklist: []string{"*/uno", "u*"}},
+			want:      names(kernel, fork, archived, disabled, template, private, internal, topical),
+		}, {
+			name:      "Status",
+			constants: config.Config{SkipForks: true, SkipArchived: true, SkipDisabled: true, SkipTemplates: true},
+			want:      names(uno, kernel, private, internal, topical),
+		}, {
+			name:      "Public",
+			constants: config.Config{Visibility: "public"},
+			want:      names(uno, kernel, fork, archived, disabled, template, topical),
+		}, {
+			name:      "Internal",
+			constants: config.Config{Visibility: "internal"},
+			want:      names(internal),
+		}, {
+			name:      "Topics",
+			constants: config.Config{Topics: []string{"security"}},
+			want:      names(topical),
+		}, {
+			name:      "Exclude Topics",
+			constants: config.Config{ExcludeTopics: []string{"GO"}},
+			want:      names(uno, kernel, fork, archived, disabled, template, private, internal),
+		}, {
+			name:      "Bad Pattern",
+			constants: config.Config{Blacklist: []string{"/(/"}},
+			wantErr:   true,
+		}, {
+			name:      "Bad Visibility",
+			constants: config.Config{Visibility: "secret"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newRepoFilter(tt.constants)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRepoFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for _, repo := range all {
+				if f.skip(repo) == "" {
+					got = append(got, repo.GetName())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("repoFilter.skip() collects %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubCloneCollector_listRepos(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	for _, name := range []string{"dos", "tres"} {
+		repo := testutil.NewDiskRepo(t, filepath.Join(constants.Cache+".remote", name))
+		repo.Commit(testutil.Commit{Author: testutil.Sig("Jane", "jane@thalesesec.net")})
+		testGitHub.AddRepo("unorepo", name, repo).Topics = []string{"go"}
+	}
+	testGitHub.SetRepoFields("unorepo/tres", map[string]interface{}{"is_template": true})
+	c := constants
+	c.Blacklist = []string{"uno"}
+	c.SkipTemplates = true
+	c.Topics = []string{"go"}
+	ghc := NewGitHubCloneCollector(c, &MockCache{})
+	filter, err := newRepoFilter(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos, err := ghc.listRepos("unorepo", filter)
+	if err != nil {
+		t.Fatalf("GitHubCloneCollector.listRepos() error = %v", err)
+	}
+	if len(repos) != 1 || repos[0].GetFullName() != "unorepo/dos" || repos[0].GetCloneURL() == "" {
+		t.Errorf("GitHubCloneCollector.listRepos() = %v, want unorepo/dos", repos)
+	}
+}
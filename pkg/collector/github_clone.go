@@ -2,8 +2,13 @@ package collector
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/github"
@@ -14,6 +19,7 @@ import (
 )
 
 var timeAfter = time.After
+var timeNow = time.Now
 
 func init() {
 	//logrus.SetLevel(logrus.DebugLevel)
@@ -21,109 +27,424 @@ func init() {
 
 //GitHubCloneCollector uses offline caching of repos to obtain stats of contribution by members and domains of interest
 type GitHubCloneCollector struct {
-	client    *github.Client
-	cache     cache.Cache
-	ctx       context.Context
-	done      chan *RepoResults
-	errs      chan error
-	constants config.Config
+	client     *github.Client
+	cache      cache.Cache
+	ctx        context.Context
+	done       chan *RepoResults
+	errs       chan error
+	constants  config.Config
+	identities *identities
+	resolver   *resolver
+	retries    *retries
+	background *backgroundLane
+	mu         sync.Mutex
+	repos      []*github.Repository
+	usage      []*cache.RepoUsage
+	collecting *collection
 }
 
 //NewGitHubCloneCollector returns a GitHubCloneCollector.
 //
 func NewGitHubCloneCollector(contants config.Config, c cache.Cache) (ghc *GitHubCloneCollector) {
 	ghc = &GitHubCloneCollector{
-		cache:     c,
-		constants: contants,
+		cache:      c,
+		constants:  contants,
+		identities: newIdentities(),
+		resolver:   newResolver(),
+		background: newBackgroundLane(),
+		retries:    newRetries(time.Duration(contants.Interval)*time.Second, contants.QuarantineAfter),
 	}
 	// Set the Client
 	ghc.client, ghc.ctx = NewV3Client(contants)
 	return
 }
 
-//RepoResults contains results from an individual repository
-type RepoResults struct {
-	Repo    string `json:"repo"`
-	Commits int64  `json:"commits"`
-	Lines   int64  `json:"lines"`
-}
-
-//CollectReport contains the results of an entire collection of repos, and an aggregated value of each stats
-type CollectReport struct {
-	Repos    []*RepoResults `json:"repos,omitempty"`
-	Commits  int64          `json:"commits"`
-	Lines    int64          `json:"lines"`
-	Projects int64          `json:"projects"`
-}
-
 //Collect iterates over all members in the organization to aggregate their OpenSource contributions offline
 func (ghc *GitHubCloneCollector) Collect() (stats *CollectReport, err error) {
 	var repos []*github.Repository
-	var done = make(chan *RepoResults)
-	var errs = make(chan error)
-	stats = &CollectReport{}
+	var w Window
+	if w.Since, w.Until, err = config.ParseWindow(ghc.constants.Since, ghc.constants.Until, timeNow()); err != nil {
+		return
+	}
+	var filter *repoFilter
+	if filter, err = newRepoFilter(ghc.constants); err != nil {
+		return
+	}
+	if err = checkLarge(ghc.constants); err != nil {
+		return
+	}
+	if err = checkStatsOptions(ghc.constants); err != nil {
+		return
+	}
+	stats = newCollectReport(w)
 	// List of Repos
 	for _, org := range ghc.constants.Organizations {
 		var tRepos []*github.Repository
-		if tRepos, _, err = ghc.client.Repositories.ListByOrg(ghc.ctx, org, nil); err != nil {
+		if tRepos, err = ghc.listRepos(org, filter); err != nil {
 			return
 		}
 		repos = append(repos, tRepos...)
 	}
+	ghc.mu.Lock()
+	ghc.repos = repos
+	ghc.mu.Unlock()
+	// Resolve the GitHub logins of members into the identities they commit with, before any repo is processed
+	ghc.setIdentities(ghc.resolveIdentities(repos))
+	// Repos that failed recently, or too often, sit this collection out
+	now := timeNow()
+	pending := make(map[string]*github.Repository, len(repos))
+	for _, repo := range repos {
+		if f := ghc.retries.skip(repo, now); f != nil {
+			logrus.Debugf("Skipping %v after %d failures", f.Repo, f.Failures)
+			stats.addFailure(f)
+			continue
+		}
+		pending[repoName(repo)] = repo
+	}
+	collect := make([]*github.Repository, 0, len(pending))
+	for _, repo := range pending {
+		collect = append(collect, repo)
+	}
+	// Publish the results as each repo finishes, until the collection is over
+	c := newCollection(w, len(collect), now)
+	for _, f := range stats.Failures {
+		c.fail(f, false)
+	}
+	ghc.setCollecting(c)
+	defer ghc.setCollecting(nil)
+	// Every repo has room for its result, so none is left blocked once the collection times out
+	done := make(chan *RepoResults, len(collect))
+	errs := make(chan error, len(collect))
 	go func() {
-		for _, repo := range repos {
-			go ghc.processRepo(repo, done, errs)
+		for _, repo := range collect {
+			go ghc.processRepo(repo, w, c, done, errs)
 		}
 	}()
 	// Drain the channels
-	for i := 1; i <= len(repos); i++ {
+	for i := 1; i <= len(collect); i++ {
 		select {
 		case d := <-done:
 			logrus.Debugf("Done with: %v", d.Repo)
-			stats.Repos = append(stats.Repos, d)
-			stats.Commits = stats.Commits + d.Commits
-			stats.Lines = stats.Lines + d.Lines
+			if repo, ok := pending[d.Repo]; ok {
+				delete(pending, d.Repo)
+				ghc.retries.succeed(repo)
+			}
+			if d.large != nil {
+				stats.addLarge(d.large)
+			}
+			// Large repos that weren't collected have no results
+			if d.large == nil || d.large.Policy == LargeWindow || d.large.Collected != nil {
+				stats.add(d)
+				c.add(d, d.large)
+			} else {
+				c.add(nil, d.large)
+			}
 		case err := <-errs:
 			logrus.Error(err)
+			f := stats.fail(err)
+			if repo, ok := pending[f.Repo]; ok {
+				delete(pending, f.Repo)
+				ghc.retries.fail(repo, f, now)
+			}
+			c.fail(f, true)
 		case <-timeAfter(10 * time.Minute):
-			return nil, errors.New("Timed out")
+			// Report what's done, and what isn't
+			names := make([]string, 0, len(pending))
+			for name := range pending {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				ghc.retries.fail(pending[name], stats.fail(newRepoError(name, StageCollect, errTimeout)), now)
+			}
+			logrus.Errorf("Timed out collecting %d repos", len(names))
+			ghc.addUsage(stats)
+			return
 		}
 	}
-	// For convenience, return a count of repos
-	stats.Projects = int64(len(stats.Repos))
 	logrus.Debugf("Finished Collecting Stats")
+	ghc.setUsage(ghc.collectGarbage(repos))
+	ghc.addUsage(stats)
 	// TODO: return and/or display the stats
 	return
 }
 
-//TODO: Process activity on a given repo for stats from this organization.
-func (ghc *GitHubCloneCollector) processRepo(repo *github.Repository, done chan *RepoResults, errs chan error) {
+//Progress returns the results so far of the collection in progress, with its progress, or nil when there's none
+func (ghc *GitHubCloneCollector) Progress() (stats *CollectReport) {
+	ghc.mu.Lock()
+	c := ghc.collecting
+	ghc.mu.Unlock()
+	if c == nil {
+		return nil
+	}
+	return c.report(timeNow())
+}
+
+//setIdentities sets the identities of the members repos are matched with
+func (ghc *GitHubCloneCollector) setIdentities(ids *identities) {
+	ghc.mu.Lock()
+	defer ghc.mu.Unlock()
+	ghc.identities = ids
+}
+
+//getIdentities returns the identities of the members repos are matched with, those of the last collection
+func (ghc *GitHubCloneCollector) getIdentities() *identities {
+	ghc.mu.Lock()
+	defer ghc.mu.Unlock()
+	return ghc.identities
+}
 
-	// Check if repo is on blacklist
-	for _, reponame := range ghc.constants.Blacklist {
-		if strings.ToLower(reponame) == strings.ToLower(repo.String()) {
-			logrus.Warn("Skipping Blacklisted item: ")
+//setUsage sets the disk usage of the cache, as of the last garbage collection
+func (ghc *GitHubCloneCollector) setUsage(usage []*cache.RepoUsage) {
+	ghc.mu.Lock()
+	defer ghc.mu.Unlock()
+	ghc.usage = usage
+}
+
+//getUsage returns the disk usage of the cache as of the last garbage collection, nil when it's unknown
+func (ghc *GitHubCloneCollector) getUsage() []*cache.RepoUsage {
+	ghc.mu.Lock()
+	defer ghc.mu.Unlock()
+	return ghc.usage
+}
+
+//setCollecting sets the collection in progress, nil when it's over
+func (ghc *GitHubCloneCollector) setCollecting(c *collection) {
+	ghc.mu.Lock()
+	defer ghc.mu.Unlock()
+	ghc.collecting = c
+}
+
+//listRepos returns the repos of an organization that pass the filter
+func (ghc *GitHubCloneCollector) listRepos(org string, filter *repoFilter) (repos []*github.Repository, err error) {
+	for page := 1; page != 0; {
+		var req *http.Request
+		if req, err = ghc.client.NewRequest("GET", fmt.Sprintf("orgs/%v/repos?per_page=100&page=%d", org, page), nil); err != nil {
+			return
+		}
+		// Topics are still a preview in older GitHub Enterprise servers
+		req.Header.Set("Accept", "application/vnd.github.mercy-preview+json")
+		var listed []*orgRepo
+		var resp *github.Response
+		if resp, err = ghc.client.Do(ghc.ctx, req, &listed); err != nil {
+			return
 		}
+		for _, repo := range listed {
+			if reason := filter.skip(repo); reason != "" {
+				logrus.Debugf("Skipping %v, it's %s", repo.GetFullName(), reason)
+				continue
+			}
+			repos = append(repos, repo.Repository)
+		}
+		page = resp.NextPage
 	}
+	return
+}
 
-	var err error
-	// First let's clone it to the local cache dir.
-	name := filepath.Join("github.com", repo.GetFullName())
-	if err = ghc.cache.Add(name, repo.GetCloneURL()); err != nil {
-		err = errors.Wrap(err, "add")
-		errs <- err
+//TODO: Process activity on a given repo for stats from this organization.
+func (ghc *GitHubCloneCollector) processRepo(repo *github.Repository, w Window, c *collection, done chan *RepoResults, errs chan error) {
+	// Huge repos would hold up the whole collection, so they're left out, sampled, or collected in the background.
+	// Their size is known from GitHub, and from the cache once they're cloned.
+	key := cacheKey(repo)
+	large := ghc.largeRepo(repo, ghc.objects(key))
+	if large == nil || large.Policy == LargeWindow {
+		c.start(repoName(repo))
+		if err := ghc.addRepo(repo); err != nil {
+			errs <- err
+			return
+		}
+		if large == nil {
+			large = ghc.largeRepo(repo, ghc.objects(key))
+		}
+	}
+	if large != nil && large.Policy != LargeWindow {
+		done <- ghc.largeResults(repo, large, w)
 		return
 	}
-
-	// Generate the scaffolding
-	r := &RepoResults{
-		Repo: name,
+	if large != nil {
+		w = ghc.largeWindow(large, w)
 	}
-	// Get Stats on cached repo...
-	if r.Commits, r.Lines, err = ghc.cache.Stats(name); err != nil {
-		err = errors.Wrap(err, "stats")
+	c.start(repoName(repo))
+	r, err := ghc.repoResults(repo, w)
+	if err != nil {
 		errs <- err
 		return
 	}
+	r.large = large
 	done <- r
 }
+
+//collectRepo clones or fetches a repo into the cache, and returns its results
+func (ghc *GitHubCloneCollector) collectRepo(repo *github.Repository, w Window) (r *RepoResults, err error) {
+	if err = ghc.addRepo(repo); err != nil {
+		return
+	}
+	return ghc.repoResults(repo, w)
+}
+
+//addRepo clones or fetches a repo into the cache
+func (ghc *GitHubCloneCollector) addRepo(repo *github.Repository) (err error) {
+	// Renamed and transferred repos keep their cache key, so index their current name
+	key := cacheKey(repo)
+	if err = ghc.cache.Index(key, repoName(repo)); err != nil {
+		logrus.Warnf("Failed to index %v: %s", repoName(repo), err)
+	}
+	// First let's clone it to the local cache dir.
+	if err = ghc.cache.Add(key, repo.GetCloneURL()); err != nil {
+		return newRepoError(repoName(repo), StageClone, err)
+	}
+	return
+}
+
+//repoResults returns the results of a cached repo
+func (ghc *GitHubCloneCollector) repoResults(repo *github.Repository, w Window) (r *RepoResults, err error) {
+	// Get Stats on cached repo...
+	var rs *cache.RepoStats
+	if rs, err = ghc.cache.Stats(cacheKey(repo), ghc.statsOptions(repo, w)); err != nil {
+		return nil, newRepoError(repoName(repo), StageStats, err)
+	}
+	r = newRepoResults(repo, rs)
+	r.collected = timeNow()
+	return
+}
+
+//Report computes stats for a time window from the repos of the last collection, without refreshing the cache.
+//Repos whose stats fail are reported as failures.  Large repos are only analyzed under the LargeWindow policy, as
+//the others would hold up the report.
+func (ghc *GitHubCloneCollector) Report(w Window) (stats *CollectReport, err error) {
+	ghc.mu.Lock()
+	repos := ghc.repos
+	ghc.mu.Unlock()
+	stats = newCollectReport(w)
+	for _, repo := range repos {
+		key := cacheKey(repo)
+		rw := w
+		if large := ghc.largeRepo(repo, ghc.objects(key)); large != nil {
+			stats.addLarge(large)
+			if large.Policy != LargeWindow {
+				continue
+			}
+			rw = ghc.largeWindow(large, w)
+		}
+		rs, err := ghc.cache.Stats(key, ghc.statsOptions(repo, rw))
+		if err != nil {
+			stats.fail(newRepoError(repoName(repo), StageStats, err))
+			continue
+		}
+		stats.add(newRepoResults(repo, rs))
+	}
+	ghc.addUsage(stats)
+	return
+}
+
+//collectGarbage removes the repos that are no longer collected from the cache, keeps it within its quota, and returns
+//the usage of the repos left, nil when it failed
+func (ghc *GitHubCloneCollector) collectGarbage(repos []*github.Repository) (usage []*cache.RepoUsage) {
+	keep := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		keep = append(keep, cacheKey(repo))
+	}
+	removed, usage, err := ghc.cache.GC(keep)
+	if err != nil {
+		logrus.Errorf("Failed to collect cache garbage: %s", err)
+	}
+	if len(removed) > 0 {
+		logrus.Infof("Removed %d repos from the cache", len(removed))
+	}
+	return
+}
+
+//addUsage adds the disk usage of the cache to a report, failures only lose the usage.  The usage of the last garbage
+//collection is reused, the cache is only walked for it when that's unknown.
+func (ghc *GitHubCloneCollector) addUsage(stats *CollectReport) {
+	usage := ghc.getUsage()
+	if usage == nil {
+		var err error
+		if usage, err = ghc.cache.Usage(); err != nil {
+			logrus.Errorf("Failed to get cache usage: %s", err)
+			return
+		}
+	}
+	stats.addUsage(usage)
+}
+
+//statsOptions returns the options used to match commits of a repo in the cache
+func (ghc *GitHubCloneCollector) statsOptions(repo *github.Repository, w Window) *cache.StatsOptions {
+	rc := ghc.constants.Repository(repo.GetFullName())
+	var exclude []string
+	if !ghc.constants.NoDefaultExcludes {
+		exclude = append(exclude, cache.DefaultExclude...)
+	}
+	exclude = append(exclude, ghc.constants.Exclude...)
+	exclude = append(exclude, rc.Exclude...)
+	var bots []string
+	if !ghc.constants.NoDefaultBots {
+		bots = append(bots, cache.DefaultBots...)
+	}
+	bots = append(bots, ghc.constants.Bots...)
+	refs := ghc.constants.Refs
+	if len(rc.Refs) > 0 {
+		refs = rc.Refs
+	}
+	ids := ghc.getIdentities()
+	return &cache.StatsOptions{
+		Members:       ids.list(),
+		Aliases:       ids.aliases(),
+		Match:         strings.ToLower(ghc.constants.Match),
+		Domains:       ghc.constants.Domains,
+		Since:         w.Since,
+		Until:         w.Until,
+		CommitterDate: strings.ToLower(ghc.constants.WindowDate) == "committer",
+		Bucket:        ghc.constants.Bucket,
+		Include:       append(append([]string{}, ghc.constants.Include...), rc.Include...),
+		Exclude:       exclude,
+		Merges:        strings.ToLower(ghc.constants.Merges),
+		Reverts:       strings.ToLower(ghc.constants.Reverts),
+		Refs:          refs,
+		Bots:          bots,
+	}
+}
+
+//checkStatsOptions returns an error for an invalid value of the options used to match and report commits
+func checkStatsOptions(constants config.Config) error {
+	switch strings.ToLower(constants.Match) {
+	case "", cache.MatchCommitter, cache.MatchAuthor, cache.MatchEither:
+	default:
+		return errors.Errorf("invalid match policy %q", constants.Match)
+	}
+	switch strings.ToLower(constants.Merges) {
+	case "", cache.MergesSkip, cache.MergesCount:
+	default:
+		return errors.Errorf("invalid merges policy %q", constants.Merges)
+	}
+	switch strings.ToLower(constants.Reverts) {
+	case "", cache.RevertsExclude, cache.RevertsNet, cache.RevertsCount:
+	default:
+		return errors.Errorf("invalid reverts policy %q", constants.Reverts)
+	}
+	switch strings.ToLower(constants.WindowDate) {
+	case "", "author", "committer":
+	default:
+		return errors.Errorf("invalid window date %q", constants.WindowDate)
+	}
+	switch strings.ToLower(constants.Bucket) {
+	case "", cache.BucketMonth, cache.BucketWeek:
+	default:
+		return errors.Errorf("invalid bucket %q", constants.Bucket)
+	}
+	return nil
+}
+
+//repoName returns the name of a repo in reports
+func repoName(repo *github.Repository) string {
+	return filepath.Join("github.com", repo.GetFullName())
+}
+
+//cacheKey returns the cache key of a repo, its GitHub ID which survives renames and transfers.  Repos without an ID
+//are keyed by name.
+func cacheKey(repo *github.Repository) string {
+	if repo.GetID() == 0 {
+		return repoName(repo)
+	}
+	return filepath.Join("github.com", "id", strconv.FormatInt(repo.GetID(), 10))
+}
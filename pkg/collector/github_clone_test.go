@@ -13,11 +13,16 @@ import (
 	"github.com/spf13/viper"
 	"github.com/thales-e-security/contribstats/pkg/cache"
 	"github.com/thales-e-security/contribstats/pkg/config"
+	"github.com/thales-e-security/contribstats/pkg/testutil"
+	"io/ioutil"
 	"os"
+	"runtime"
+	"strings"
 	"time"
 )
 
-var testCache = cache.NewGitCache(cache.DefaultCache)
+var testCache cache.Cache
+var testGitHub *testutil.GitHub
 var constants config.Config
 
 func init() {
@@ -36,16 +41,44 @@ func init() {
 	viper.SetConfigName(".contribstats")
 }
 
+//setupTestCase serves the unorepo organization from a GitHub fake, whose uno repo has a commit by someone else,
+//one by a domain address, and one by the private address of the member janedoe
 func setupTestCase(t *testing.T) func(t *testing.T) {
+	td, err := ioutil.TempDir("", "collector")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := testutil.NewDiskRepo(t, filepath.Join(td, "remote", "uno"))
+	repo.Commit(testutil.Commit{
+		Author: testutil.Sig("Someone", "someone@example.com"),
+		Files:  map[string]string{"README.md": "# uno\n"},
+	})
+	repo.Commit(testutil.Commit{
+		Author: testutil.Sig("John", "john@thalesesec.net"),
+		Files:  map[string]string{"uno.go": "package uno\n"},
+	})
+	repo.Commit(testutil.Commit{
+		Author: testutil.Sig("Jane", "jane.doe@example.com"),
+		Files:  map[string]string{"dos.go": "package uno\n\nconst Dos = 2\n"},
+	})
+	gh := testutil.NewGitHub(t)
+	gh.AddRepo("unorepo", "uno", repo)
+	gh.AddUser("janedoe", "jane.doe@example.com")
+	gh.AddMember("unorepo", "janedoe")
 	constants = config.Config{
 		Organizations: []string{"unorepo"},
 		Domains:       []string{"thalesesec.net", "thales-e-security.com"},
-		Cache:         filepath.Join(os.TempDir(), t.Name()),
+		Cache:         filepath.Join(td, "cache"),
 		Interval:      10,
-		Token:         os.Getenv("CONTRIBSTATS_TOKEN"),
+		Token:         "token",
+		GitHubURL:     gh.URL,
 	}
+	testCache = cache.NewGitCache(constants.Cache)
+	testGitHub = gh
 	return func(t *testing.T) {
 		t.Log("teardown test case")
+		gh.Close()
+		os.RemoveAll(td)
 	}
 }
 
@@ -92,13 +125,19 @@ func TestNewGitHubCloneCollector(t *testing.T) {
 func TestGitHubCloneCollector_Collect(t *testing.T) {
 	teardown := setupTestCase(t)
 	defer teardown(t)
+	// Hold up the repos of the timeout
+	wait := make(chan struct{})
+	defer close(wait)
 	tests := []struct {
 		name          string
 		ghc           *GitHubCloneCollector
 		wantStats     bool
 		wantErr       bool
 		wantTimeout   bool
+		wantCommits   int64
+		wantFailures  []string
 		organizations []string
+		since         string
 	}{
 
 		{
@@ -106,6 +145,7 @@ func TestGitHubCloneCollector_Collect(t *testing.T) {
 			ghc:           NewGitHubCloneCollector(constants, testCache),
 			wantStats:     true,
 			wantErr:       false,
+			wantCommits:   2,
 			organizations: []string{"unorepo"},
 		},
 		{
@@ -115,11 +155,24 @@ func TestGitHubCloneCollector_Collect(t *testing.T) {
 			wantErr:       true,
 			organizations: []string{"tthales-e-security"},
 		}, {
-			name:          "Timeout",
+			name:          "Error Window",
 			ghc:           NewGitHubCloneCollector(constants, testCache),
 			wantStats:     false,
 			wantErr:       true,
+			organizations: []string{"unorepo"},
+			since:         "yesterday",
+		}, {
+			name:          "Error Clone",
+			ghc:           NewGitHubCloneCollector(constants, &MockCache{add: true}),
+			wantStats:     true,
+			wantFailures:  []string{FailureClone},
+			organizations: []string{"unorepo"},
+		}, {
+			name:          "Timeout",
+			ghc:           NewGitHubCloneCollector(constants, &MockCache{wait: wait}),
+			wantStats:     true,
 			wantTimeout:   true,
+			wantFailures:  []string{FailureTimeout},
 			organizations: []string{"unorepo"},
 		},
 	}
@@ -128,6 +181,7 @@ func TestGitHubCloneCollector_Collect(t *testing.T) {
 			if tt.organizations != nil {
 				tt.ghc.constants.Organizations = tt.organizations
 			}
+			tt.ghc.constants.Since = tt.since
 			if tt.wantTimeout {
 				timeAfter = func(d time.Duration) <-chan time.Time {
 					return time.After(time.Millisecond)
@@ -144,16 +198,69 @@ func TestGitHubCloneCollector_Collect(t *testing.T) {
 				t.Errorf("GitHubCloneCollector.Collect() stats = %v, wantStats %v", (gotStats != nil), tt.wantStats)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
+			if gotStats.Commits != tt.wantCommits {
+				t.Errorf("GitHubCloneCollector.Collect() commits = %v, want %v", gotStats.Commits, tt.wantCommits)
+			}
+			checkFailures(t, gotStats, tt.wantFailures)
 
 		})
 	}
 }
 
+func TestGitHubCloneCollector_Collect_timeout(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	timeAfter = func(d time.Duration) <-chan time.Time {
+		return time.After(time.Millisecond)
+	}
+	defer func() { timeAfter = time.After }()
+	wait := make(chan struct{})
+	ghc := NewGitHubCloneCollector(constants, &MockCache{wait: wait})
+	if _, err := ghc.Collect(); err != nil {
+		t.Fatalf("GitHubCloneCollector.Collect() error = %v", err)
+	}
+	// Repos still being processed finish after the collection timed out, rather than being left blocked
+	close(wait)
+	buf := make([]byte, 1<<20)
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		if !strings.Contains(stacks, "processRepo") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GitHubCloneCollector.Collect() left repos blocked after timing out:\n%s", stacks)
+		}
+	}
+}
+
+func TestGitHubCloneCollector_Collect_retries(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	timeAfter = time.After
+	ghc := NewGitHubCloneCollector(constants, &MockCache{add: true})
+	for i, wantSkipped := range []bool{false, true} {
+		gotStats, err := ghc.Collect()
+		if err != nil {
+			t.Fatalf("GitHubCloneCollector.Collect() error = %v", err)
+		}
+		if len(gotStats.Failures) != 1 {
+			t.Fatalf("GitHubCloneCollector.Collect() failures = %v, want 1", len(gotStats.Failures))
+		}
+		f := gotStats.Failures[0]
+		if f.Skipped != wantSkipped || f.Failures != 1 || f.Retry == nil || f.Kind != FailureClone {
+			t.Errorf("GitHubCloneCollector.Collect() #%d failure = %+v, want skipped %v after 1 failure", i, f, wantSkipped)
+		}
+	}
+}
+
 func TestGitHubCloneCollector_processRepo(t *testing.T) {
 	teardown := setupTestCase(t)
 	defer teardown(t)
 	rs, ctx := NewV3Client(constants)
-	repo, _, err := rs.Repositories.Get(ctx, "thales-e-security", "linux-kernel")
+	repo, _, err := rs.Repositories.Get(ctx, "unorepo", "uno")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -170,7 +277,7 @@ func TestGitHubCloneCollector_processRepo(t *testing.T) {
 	}{
 		{
 			name: "Good",
-			ghc:  NewGitHubCloneCollector(constants, cache.NewGitCache(cache.DefaultCache)),
+			ghc:  NewGitHubCloneCollector(constants, testCache),
 			args: args{
 				repo: repo,
 				done: make(chan *RepoResults, 1),
@@ -201,7 +308,7 @@ func TestGitHubCloneCollector_processRepo(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.ghc.processRepo(tt.args.repo, tt.args.done, tt.args.errs)
+			tt.ghc.processRepo(tt.args.repo, Window{}, newCollection(Window{}, 1, timeNow()), tt.args.done, tt.args.errs)
 			select {
 			case err := <-tt.args.errs:
 				if (err != nil) != tt.wantErr {
@@ -216,9 +323,149 @@ func TestGitHubCloneCollector_processRepo(t *testing.T) {
 	}
 }
 
+func TestGitHubCloneCollector_Report(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	repos := []*github.Repository{
+		{FullName: github.String("unorepo/uno")},
+		{FullName: github.String("unorepo/dos")},
+	}
+	since := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		ghc          *GitHubCloneCollector
+		w            Window
+		wantRepos    int
+		wantFailures []string
+		wantErr      bool
+	}{
+		{
+			name:      "OK",
+			ghc:       NewGitHubCloneCollector(constants, &MockCache{}),
+			w:         Window{Since: since},
+			wantRepos: 2,
+		}, {
+			name:         "Error Stats",
+			ghc:          NewGitHubCloneCollector(constants, &MockCache{stats: true}),
+			wantFailures: []string{FailureStats, FailureStats},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ghc.repos = repos
+			gotStats, err := tt.ghc.Report(tt.w)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GitHubCloneCollector.Report() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(gotStats.Repos) != tt.wantRepos || gotStats.Projects != int64(tt.wantRepos) {
+				t.Errorf("GitHubCloneCollector.Report() repos = %v, want %v", len(gotStats.Repos), tt.wantRepos)
+			}
+			checkFailures(t, gotStats, tt.wantFailures)
+			if len(tt.wantFailures) > 0 {
+				return
+			}
+			if gotStats.Lines != gotStats.Additions+gotStats.Deletions || gotStats.Additions != int64(2*tt.wantRepos) {
+				t.Errorf("GitHubCloneCollector.Report() additions = %v, lines = %v", gotStats.Additions, gotStats.Lines)
+			}
+			if len(gotStats.Languages) != 1 || gotStats.Languages["Go"].Additions != int64(2*tt.wantRepos) {
+				t.Errorf("GitHubCloneCollector.Report() languages = %v, want Go with %v additions", gotStats.Languages, 2*tt.wantRepos)
+			}
+			if len(gotStats.Series) != 1 || gotStats.Series[0].Commits != int64(tt.wantRepos) {
+				t.Errorf("GitHubCloneCollector.Report() series = %v, want one bucket of %v commits", gotStats.Series, tt.wantRepos)
+			}
+			if gotStats.Since == nil || !gotStats.Since.Equal(since) || gotStats.Until != nil {
+				t.Errorf("GitHubCloneCollector.Report() window = %v - %v, want %v", gotStats.Since, gotStats.Until, since)
+			}
+			if gotStats.CacheSize != 1024 {
+				t.Errorf("GitHubCloneCollector.Report() cache size = %v, want 1024", gotStats.CacheSize)
+			}
+		})
+	}
+}
+
+//checkFailures checks the kinds of the failures of a report, and that it's only complete without any
+func checkFailures(t *testing.T, stats *CollectReport, want []string) {
+	var got []string
+	for _, f := range stats.Failures {
+		if f.Repo == "" || f.Stage == "" || f.Message == "" {
+			t.Errorf("failure = %+v, want a repo, stage and message", f)
+		}
+		got = append(got, f.Kind)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("failures = %v, want %v", got, want)
+	}
+	if stats.Complete != (len(want) == 0) {
+		t.Errorf("complete = %v, want %v", stats.Complete, len(want) == 0)
+	}
+}
+
+func TestGitHubCloneCollector_statsOptions(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	repo := &github.Repository{FullName: github.String("unorepo/uno")}
+	c := constants
+	c.Include = []string{"src/**"}
+	c.Exclude = []string{"docs/**"}
+	c.Refs = []string{"default", "refs/tags/v*"}
+	tests := []struct {
+		name              string
+		noDefaultExcludes bool
+		repoRefs          []string
+		wantInclude       []string
+		wantExclude       []string
+		wantRefs          []string
+	}{
+		{
+			name:        "Defaults",
+			wantInclude: []string{"src/**"},
+			wantExclude: append(append([]string{}, cache.DefaultExclude...), "docs/**", "*.txt"),
+			wantRefs:    []string{"default", "refs/tags/v*"},
+		}, {
+			name:              "No Defaults",
+			noDefaultExcludes: true,
+			wantInclude:       []string{"src/**"},
+			wantExclude:       []string{"docs/**", "*.txt"},
+			wantRefs:          []string{"default", "refs/tags/v*"},
+		}, {
+			name:        "Repo Refs",
+			repoRefs:    []string{"branches"},
+			wantInclude: []string{"src/**"},
+			wantExclude: append(append([]string{}, cache.DefaultExclude...), "docs/**", "*.txt"),
+			wantRefs:    []string{"branches"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.NoDefaultExcludes = tt.noDefaultExcludes
+			c.Repositories = map[string]config.RepoConfig{
+				"unorepo/uno": {Exclude: []string{"*.txt"}, Refs: tt.repoRefs},
+			}
+			got := NewGitHubCloneCollector(c, &MockCache{}).statsOptions(repo, Window{})
+			if !reflect.DeepEqual(got.Include, tt.wantInclude) {
+				t.Errorf("GitHubCloneCollector.statsOptions() include = %v, want %v", got.Include, tt.wantInclude)
+			}
+			if !reflect.DeepEqual(got.Exclude, tt.wantExclude) {
+				t.Errorf("GitHubCloneCollector.statsOptions() exclude = %v, want %v", got.Exclude, tt.wantExclude)
+			}
+			if !reflect.DeepEqual(got.Refs, tt.wantRefs) {
+				t.Errorf("GitHubCloneCollector.statsOptions() refs = %v, want %v", got.Refs, tt.wantRefs)
+			}
+		})
+	}
+}
+
 type MockCache struct {
 	add   bool
 	stats bool
+	// wait holds up Add until it's closed
+	wait chan struct{}
+	// objects is the number of objects of every repo
+	objects int64
 }
 
 func (mc *MockCache) Path() string {
@@ -226,6 +473,9 @@ func (mc *MockCache) Path() string {
 }
 
 func (mc *MockCache) Add(repo, url string) (err error) {
+	if mc.wait != nil {
+		<-mc.wait
+	}
 	if mc.add {
 		err = errors.New("expected error")
 
@@ -233,10 +483,111 @@ func (mc *MockCache) Add(repo, url string) (err error) {
 	return
 }
 
-func (mc *MockCache) Stats(repo string) (commits int64, lines int64, err error) {
+func (mc *MockCache) Index(repo, name string) (err error) {
+	return
+}
+func (mc *MockCache) Objects(repo string) (objects int64, err error) {
+	return mc.objects, nil
+}
+func (mc *MockCache) Usage() (usage []*cache.RepoUsage, err error) {
+	return []*cache.RepoUsage{{Repo: "github.com/unorepo/uno", Size: 1024}}, nil
+}
+func (mc *MockCache) GC(keep []string) (removed []string, usage []*cache.RepoUsage, err error) {
+	usage, err = mc.Usage()
+	return
+}
+func (mc *MockCache) Stats(repo string, opts *cache.StatsOptions) (stats *cache.RepoStats, err error) {
 	if mc.stats {
 		err = errors.New("expected error")
-
+		return
+	}
+	stats = &cache.RepoStats{
+		Commits:   1,
+		Additions: 2,
+		Deletions: 1,
+		Lines:     3,
+		Series: cache.Series{
+			{Start: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Commits: 1, Additions: 2, Deletions: 1, Lines: 3},
+		},
+		Languages: cache.Languages{
+			"Go": {Additions: 2, Deletions: 1},
+		},
 	}
 	return
 }
+
+func Test_checkStatsOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		constants config.Config
+		wantErr   bool
+	}{
+		{
+			name: "Default",
+		}, {
+			name:      "Match",
+			constants: config.Config{Match: "Either"},
+		}, {
+			name:      "Bad Match",
+			constants: config.Config{Match: "member"},
+			wantErr:   true,
+		}, {
+			name:      "Policies",
+			constants: config.Config{Merges: "Count", Reverts: "net"},
+		}, {
+			name:      "Bad Merges",
+			constants: config.Config{Merges: "none"},
+			wantErr:   true,
+		}, {
+			name:      "Bad Reverts",
+			constants: config.Config{Reverts: "netted"},
+			wantErr:   true,
+		}, {
+			name:      "Window Date",
+			constants: config.Config{WindowDate: "Committer"},
+		}, {
+			name:      "Bad Window Date",
+			constants: config.Config{WindowDate: "comitter"},
+			wantErr:   true,
+		}, {
+			name:      "Bucket",
+			constants: config.Config{Bucket: "Week"},
+		}, {
+			name:      "Bad Bucket",
+			constants: config.Config{Bucket: "day"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkStatsOptions(tt.constants); (err != nil) != tt.wantErr {
+				t.Errorf("checkStatsOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_cacheKey(t *testing.T) {
+	tests := []struct {
+		name string
+		repo *github.Repository
+		want string
+	}{
+		{
+			name: "ID",
+			repo: &github.Repository{ID: github.Int64(1234), FullName: github.String("unorepo/uno")},
+			want: filepath.Join("github.com", "id", "1234"),
+		}, {
+			name: "No ID",
+			repo: &github.Repository{FullName: github.String("unorepo/uno")},
+			want: filepath.Join("github.com", "unorepo", "uno"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cacheKey(tt.repo); got != tt.want {
+				t.Errorf("cacheKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
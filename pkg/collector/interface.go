@@ -5,4 +5,8 @@ package collector
 type Collector interface {
 	// Collects stats from the API, and returns the values as a []byte of JSON content
 	Collect() (stats *CollectReport, err error)
+	// Report computes stats for a time window from previously collected content, without collecting again
+	Report(w Window) (stats *CollectReport, err error)
+	// Progress returns the results so far of the collection in progress, with its Progress, or nil when there's none
+	Progress() (stats *CollectReport)
 }
@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/thales-e-security/contribstats/pkg/config"
+)
+
+//Policies for the repos over the size limits
+const (
+	// LargeSkip leaves large repos out
+	LargeSkip = "skip"
+	// LargeWindow only analyzes the recent history of large repos, see config.Config.LargeRepoWindow
+	LargeWindow = "window"
+	// LargeBackground analyzes large repos one at a time without holding up collections, which report their last stats
+	LargeBackground = "background"
+)
+
+//defaultLargeRepoWindow is the history analyzed of large repos under the LargeWindow policy
+const defaultLargeRepoWindow = "1y"
+
+//LargeRepo is a repo over the size limits, and how it's collected
+type LargeRepo struct {
+	Repo string `json:"repo"`
+	// Size is the size of the repo according to GitHub, in kilobytes
+	Size int64 `json:"size,omitempty"`
+	// Objects is the number of objects of the cached repo, when it's known
+	Objects int64  `json:"objects,omitempty"`
+	Policy  string `json:"policy"`
+	// Since is the start of the history analyzed under the LargeWindow policy
+	Since *time.Time `json:"since,omitempty"`
+	// Collected is when the stats of a repo under the LargeBackground policy were computed, none yet when nil
+	Collected *time.Time `json:"collected,omitempty"`
+	// Error is the last failure of a repo under the LargeBackground policy
+	Error string `json:"error,omitempty"`
+}
+
+//checkLarge returns an error for an invalid large repo policy or window
+func checkLarge(constants config.Config) error {
+	switch largePolicy(constants) {
+	case LargeSkip, LargeWindow, LargeBackground:
+	default:
+		return errors.Errorf("invalid large repos policy %q", constants.LargeRepos)
+	}
+	if _, err := config.ParseTime(largeRepoWindow(constants), timeNow()); err != nil {
+		return errors.Wrap(err, "large repo window")
+	}
+	return nil
+}
+
+func largePolicy(constants config.Config) string {
+	if constants.LargeRepos == "" {
+		return LargeSkip
+	}
+	return strings.ToLower(constants.LargeRepos)
+}
+
+func largeRepoWindow(constants config.Config) string {
+	if constants.LargeRepoWindow == "" {
+		return defaultLargeRepoWindow
+	}
+	return constants.LargeRepoWindow
+}
+
+//largeRepo returns how a repo of a size is collected when it's over the limits, or nil when it isn't.  An unknown
+//number of objects is zero.
+func (ghc *GitHubCloneCollector) largeRepo(repo *github.Repository, objects int64) *LargeRepo {
+	c := ghc.constants
+	size := int64(repo.GetSize())
+	if (c.MaxRepoSize <= 0 || size <= int64(c.MaxRepoSize)*1024) && (c.MaxRepoObjects <= 0 || objects <= int64(c.MaxRepoObjects)) {
+		return nil
+	}
+	return &LargeRepo{
+		Repo:    repoName(repo),
+		Size:    size,
+		Objects: objects,
+		Policy:  largePolicy(c),
+	}
+}
+
+//objects returns the number of objects of a cached repo when there's a limit to it, failures are taken as zero
+func (ghc *GitHubCloneCollector) objects(key string) int64 {
+	if ghc.constants.MaxRepoObjects <= 0 {
+		return 0
+	}
+	objects, err := ghc.cache.Objects(key)
+	if err != nil {
+		logrus.Debugf("Failed to count the objects of %v: %s", key, err)
+	}
+	return objects
+}
+
+//largeWindow narrows a window to the history analyzed of large repos, and records it
+func (ghc *GitHubCloneCollector) largeWindow(large *LargeRepo, w Window) Window {
+	since, _ := config.ParseTime(largeRepoWindow(ghc.constants), timeNow())
+	if since.After(w.Since) {
+		w.Since = since
+	}
+	large.Since = &w.Since
+	return w
+}
+
+//largeResults returns the results of a large repo that isn't collected now, which are empty unless they were
+//collected in the background
+func (ghc *GitHubCloneCollector) largeResults(repo *github.Repository, large *LargeRepo, w Window) *RepoResults {
+	if large.Policy == LargeBackground {
+		if r := ghc.background.results(repo, large); r != nil {
+			ghc.background.add(ghc, repo, w)
+			return r
+		}
+		ghc.background.add(ghc, repo, w)
+	}
+	return &RepoResults{Repo: large.Repo, large: large}
+}
+
+//backgroundLane collects large repos one at a time, so they don't hold up the collection of the others.  Repos are
+//keyed like the cache, so their results follow them across renames and transfers.
+type backgroundLane struct {
+	sync.Mutex
+	queue   []backgroundRepo
+	queued  map[string]bool
+	done    map[string]*RepoResults
+	errs    map[string]string
+	running bool
+}
+
+//backgroundRepo is a repo queued for collection in the background, for a window
+type backgroundRepo struct {
+	repo *github.Repository
+	w    Window
+}
+
+func newBackgroundLane() *backgroundLane {
+	return &backgroundLane{
+		queued: make(map[string]bool),
+		done:   make(map[string]*RepoResults),
+		errs:   make(map[string]string),
+	}
+}
+
+//add queues a repo unless it already is, and starts collecting the queue unless it already is
+func (bl *backgroundLane) add(ghc *GitHubCloneCollector, repo *github.Repository, w Window) {
+	bl.Lock()
+	defer bl.Unlock()
+	key := cacheKey(repo)
+	if bl.queued[key] {
+		return
+	}
+	bl.queued[key] = true
+	bl.queue = append(bl.queue, backgroundRepo{repo: repo, w: w})
+	if !bl.running {
+		bl.running = true
+		go bl.run(ghc)
+	}
+}
+
+//run collects the queued repos until there are none left
+func (bl *backgroundLane) run(ghc *GitHubCloneCollector) {
+	for {
+		bl.Lock()
+		if len(bl.queue) == 0 {
+			bl.running = false
+			bl.Unlock()
+			return
+		}
+		br := bl.queue[0]
+		bl.queue = bl.queue[1:]
+		bl.Unlock()
+		key := cacheKey(br.repo)
+		logrus.Infof("Collecting %v in the background", repoName(br.repo))
+		r, err := ghc.collectRepo(br.repo, br.w)
+		bl.Lock()
+		delete(bl.queued, key)
+		if err != nil {
+			logrus.Error(err)
+			bl.errs[key] = err.Error()
+		} else {
+			delete(bl.errs, key)
+			bl.done[key] = r
+		}
+		bl.Unlock()
+	}
+}
+
+//results returns a copy of the last results of a repo collected in the background, or nil, and records when they
+//were collected, or why they failed, on large
+func (bl *backgroundLane) results(repo *github.Repository, large *LargeRepo) *RepoResults {
+	bl.Lock()
+	defer bl.Unlock()
+	key := cacheKey(repo)
+	large.Error = bl.errs[key]
+	r, ok := bl.done[key]
+	if !ok {
+		return nil
+	}
+	collected := r.collected
+	large.Collected = &collected
+	copied := *r
+	// The repo may have been renamed since it was collected
+	copied.Repo = repoName(repo)
+	copied.Organization = repo.GetOwner().GetLogin()
+	copied.large = large
+	return &copied
+}
+
+//addLarge lists a large repo in the report
+func (stats *CollectReport) addLarge(large *LargeRepo) {
+	stats.Large = append(stats.Large, large)
+}
@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/thales-e-security/contribstats/pkg/config"
+)
+
+func Test_checkLarge(t *testing.T) {
+	tests := []struct {
+		name      string
+		constants config.Config
+		wantErr   bool
+	}{
+		{
+			name: "Default",
+		}, {
+			name:      "Window",
+			constants: config.Config{LargeRepos: "Window", LargeRepoWindow: "90d"},
+		}, {
+			name:      "Bad Policy",
+			constants: config.Config{LargeRepos: "sample"},
+			wantErr:   true,
+		}, {
+			name:      "Bad Window",
+			constants: config.Config{LargeRepos: "window", LargeRepoWindow: "yesterday"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkLarge(tt.constants); (err != nil) != tt.wantErr {
+				t.Errorf("checkLarge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitHubCloneCollector_largeRepo(t *testing.T) {
+	repo := testRepo("unorepo", "linux-kernel")
+	repo.Size = github.Int(2048)
+	tests := []struct {
+		name      string
+		constants config.Config
+		objects   int64
+		want      bool
+	}{
+		{
+			name: "Unlimited",
+		}, {
+			name:      "Size",
+			constants: config.Config{MaxRepoSize: 1},
+			want:      true,
+		}, {
+			name:      "Small",
+			constants: config.Config{MaxRepoSize: 2},
+		}, {
+			name:      "Objects",
+			constants: config.Config{MaxRepoObjects: 100},
+			objects:   101,
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghc := &GitHubCloneCollector{constants: tt.constants}
+			got := ghc.largeRepo(repo, tt.objects)
+			if (got != nil) != tt.want {
+				t.Fatalf("GitHubCloneCollector.largeRepo() = %+v, want %v", got, tt.want)
+			}
+			if got != nil && (got.Size != 2048 || got.Objects != tt.objects || got.Policy != LargeSkip) {
+				t.Errorf("GitHubCloneCollector.largeRepo() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestGitHubCloneCollector_Collect_large(t *testing.T) {
+	timeAfter = time.After
+	tests := []struct {
+		name        string
+		constants   func(c *config.Config)
+		wantCommits []int64
+		wantRepos   []int64
+		wantSince   bool
+	}{
+		{
+			name: "Skip",
+			constants: func(c *config.Config) {
+				c.MaxRepoSize = 1
+			},
+			wantCommits: []int64{0},
+			wantRepos:   []int64{0},
+		}, {
+			name: "Skip Objects",
+			constants: func(c *config.Config) {
+				c.MaxRepoObjects = 1
+			},
+			wantCommits: []int64{0, 0},
+			wantRepos:   []int64{0, 0},
+		}, {
+			name: "Window",
+			constants: func(c *config.Config) {
+				c.MaxRepoSize = 1
+				c.LargeRepos = LargeWindow
+			},
+			wantCommits: []int64{0},
+			wantRepos:   []int64{1},
+			wantSince:   true,
+		}, {
+			name: "Window Since",
+			constants: func(c *config.Config) {
+				c.MaxRepoSize = 1
+				c.LargeRepos = LargeWindow
+				c.LargeRepoWindow = "2017-01-01"
+			},
+			wantCommits: []int64{2},
+			wantRepos:   []int64{1},
+			wantSince:   true,
+		}, {
+			name: "Background",
+			constants: func(c *config.Config) {
+				c.MaxRepoSize = 1
+				c.LargeRepos = LargeBackground
+			},
+			wantCommits: []int64{0, 2},
+			wantRepos:   []int64{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teardown := setupTestCase(t)
+			defer teardown(t)
+			testGitHub.SetRepoFields("unorepo/uno", map[string]interface{}{"size": 2048})
+			c := constants
+			tt.constants(&c)
+			ghc := NewGitHubCloneCollector(c, testCache)
+			for i := range tt.wantCommits {
+				gotStats, err := ghc.Collect()
+				if err != nil {
+					t.Fatalf("GitHubCloneCollector.Collect() error = %v", err)
+				}
+				if gotStats.Commits != tt.wantCommits[i] || gotStats.Projects != tt.wantRepos[i] {
+					t.Errorf("GitHubCloneCollector.Collect() #%d commits = %v, projects = %v, want %v, %v", i,
+						gotStats.Commits, gotStats.Projects, tt.wantCommits[i], tt.wantRepos[i])
+				}
+				if len(gotStats.Large) > 0 && gotStats.Large[0].Error != "" {
+					t.Errorf("GitHubCloneCollector.Collect() #%d large error = %v", i, gotStats.Large[0].Error)
+				}
+				if !gotStats.Complete || len(gotStats.Large) != 1 || gotStats.Large[0].Repo != "github.com/unorepo/uno" {
+					t.Fatalf("GitHubCloneCollector.Collect() #%d complete = %v, large = %v", i, gotStats.Complete, gotStats.Large)
+				}
+				if large := gotStats.Large[0]; (large.Since != nil) != tt.wantSince {
+					t.Errorf("GitHubCloneCollector.Collect() #%d since = %v, want %v", i, large.Since, tt.wantSince)
+				}
+				waitBackground(t, ghc)
+			}
+		})
+	}
+}
+
+func Test_backgroundLane_renamed(t *testing.T) {
+	repo := testRepo("unorepo", "uno")
+	repo.ID = github.Int64(1234)
+	renamed := testRepo("otherorg", "dos")
+	renamed.ID = github.Int64(1234)
+	bl := newBackgroundLane()
+	// Nothing is collected while the lane is marked as running
+	bl.running = true
+	bl.add(nil, repo, Window{})
+	bl.add(nil, renamed, Window{})
+	if len(bl.queue) != 1 {
+		t.Errorf("backgroundLane.add() queued %d repos, want the renamed repo once", len(bl.queue))
+	}
+	bl.done[cacheKey(repo)] = &RepoResults{Repo: repoName(repo), Organization: "unorepo", Commits: 2}
+	large := &LargeRepo{Repo: repoName(renamed)}
+	r := bl.results(renamed, large)
+	if r == nil || r.Commits != 2 || r.Repo != repoName(renamed) || r.Organization != "otherorg" || large.Collected == nil {
+		t.Errorf("backgroundLane.results() of the renamed repo = %+v, want its results as %v", r, repoName(renamed))
+	}
+}
+
+//waitBackground waits for the background lane to be done
+func waitBackground(t *testing.T, ghc *GitHubCloneCollector) {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		ghc.background.Lock()
+		running := ghc.background.running
+		ghc.background.Unlock()
+		if !running {
+			return
+		}
+	}
+	t.Fatal("the background lane didn't finish")
+}
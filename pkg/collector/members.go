@@ -0,0 +1,277 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// noreplyDomain is the domain GitHub uses for private commit email addresses
+const noreplyDomain = "users.noreply.github.com"
+
+//identities are the email addresses members commit with, and the logins that own them.  They're built afresh by each
+//collection, before any repo is processed, so every repo is matched against the same identities.
+type identities struct {
+	sync.Mutex
+	emails map[string]bool
+	owners map[string]string
+}
+
+func newIdentities() *identities {
+	return &identities{
+		emails: make(map[string]bool),
+		owners: make(map[string]string),
+	}
+}
+
+//resolver looks up the email addresses GitHub logins commit with, memoizing the lookups across collections.  Once
+//GitHub's rate limit is hit it stops looking up until the limit resets, and makes do with what it has.
+type resolver struct {
+	sync.Mutex
+	users   map[string]*github.User
+	authors map[string]*repoAuthors
+	// reset is when GitHub's rate limit resets, after it was hit
+	reset time.Time
+}
+
+//repoAuthors are the email addresses the logins of the recent commits of a repo authored them with, as of a push
+type repoAuthors struct {
+	pushed time.Time
+	emails map[string][]string
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		users:   make(map[string]*github.User),
+		authors: make(map[string]*repoAuthors),
+	}
+}
+
+//limited returns whether lookups wait for GitHub's rate limit to reset
+func (r *resolver) limited(now time.Time) bool {
+	r.Lock()
+	defer r.Unlock()
+	return now.Before(r.reset)
+}
+
+//hit returns whether err says GitHub's rate limit was hit, and if so records when it resets
+func (r *resolver) hit(err error) bool {
+	var reset time.Time
+	switch e := errors.Cause(err).(type) {
+	case *github.RateLimitError:
+		reset = e.Rate.Reset.Time
+	case *github.AbuseRateLimitError:
+		retry := e.GetRetryAfter()
+		if retry < time.Minute {
+			retry = time.Minute
+		}
+		reset = timeNow().Add(retry)
+	default:
+		return false
+	}
+	r.Lock()
+	defer r.Unlock()
+	if reset.After(r.reset) {
+		r.reset = reset
+		logrus.Warnf("Hit GitHub's rate limit, member lookups resume at %v", reset)
+	}
+	return true
+}
+
+//add records emails as belonging to a member
+func (id *identities) add(emails ...string) {
+	id.Lock()
+	defer id.Unlock()
+	for _, email := range emails {
+		if email != "" {
+			id.emails[strings.ToLower(email)] = true
+		}
+	}
+}
+
+//addFor records emails as belonging to the member with the given login
+func (id *identities) addFor(login string, emails ...string) {
+	id.add(emails...)
+	id.Lock()
+	defer id.Unlock()
+	for _, email := range emails {
+		if email != "" {
+			id.owners[strings.ToLower(email)] = strings.ToLower(login)
+		}
+	}
+}
+
+//aliases returns a copy of the known member emails mapped to the login that owns them
+func (id *identities) aliases() (aliases map[string]string) {
+	id.Lock()
+	defer id.Unlock()
+	aliases = make(map[string]string, len(id.owners))
+	for email, login := range id.owners {
+		aliases[email] = login
+	}
+	return
+}
+
+//list returns all known member emails, sorted
+func (id *identities) list() (emails []string) {
+	id.Lock()
+	defer id.Unlock()
+	for email := range id.emails {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return
+}
+
+//splitMembers separates configured members into email addresses and GitHub logins
+func splitMembers(members []string) (emails []string, logins []string) {
+	for _, member := range members {
+		if strings.Contains(member, "@") {
+			emails = append(emails, member)
+		} else if member != "" {
+			logins = append(logins, member)
+		}
+	}
+	return
+}
+
+//noreplyEmails returns the noreply addresses GitHub generates for a user, both the legacy and the ID prefixed forms
+func noreplyEmails(login string, id int64) (emails []string) {
+	emails = append(emails, fmt.Sprintf("%s@%s", login, noreplyDomain))
+	if id != 0 {
+		emails = append(emails, fmt.Sprintf("%d+%s@%s", id, login, noreplyDomain))
+	}
+	return
+}
+
+//resolveIdentities returns the identities of the configured members, and of the members of the organizations when
+//a token is available.  The logins among them are resolved to their noreply, public, and commit email addresses, as
+//found in the recent commits of repos.
+func (ghc *GitHubCloneCollector) resolveIdentities(repos []*github.Repository) *identities {
+	ids := newIdentities()
+	emails, logins := splitMembers(ghc.constants.Members)
+	ids.add(emails...)
+	logins = append(logins, ghc.orgMembers()...)
+	if len(logins) == 0 {
+		return ids
+	}
+	for _, login := range logins {
+		user := ghc.user(login)
+		ids.addFor(login, noreplyEmails(user.GetLogin(), user.GetID())...)
+		ids.addFor(login, user.GetEmail())
+	}
+	// One page of commits per repo, rather than per member and repo
+	authors := make([]map[string][]string, 0, len(repos))
+	for _, repo := range repos {
+		authors = append(authors, ghc.repoAuthors(repo))
+	}
+	for _, login := range logins {
+		for _, emails := range authors {
+			ids.addFor(login, emails[strings.ToLower(login)]...)
+		}
+	}
+	return ids
+}
+
+//orgMembers returns the logins of the members of the organizations, when a token is available
+func (ghc *GitHubCloneCollector) orgMembers() (logins []string) {
+	if ghc.constants.Token == "" {
+		return
+	}
+	for _, org := range ghc.constants.Organizations {
+		opt := &github.ListMembersOptions{
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+		for {
+			users, resp, err := ghc.client.Organizations.ListMembers(ghc.ctx, org, opt)
+			if err != nil {
+				// Most likely the token is missing the read:org scope, so carry on with what is configured
+				logrus.Warnf("Unable to list members of %v: %v", org, err)
+				break
+			}
+			for _, user := range users {
+				logins = append(logins, user.GetLogin())
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opt.Page = resp.NextPage
+		}
+	}
+	return
+}
+
+//user returns the GitHub user of a login, or a user with only the login when it can't be looked up
+func (ghc *GitHubCloneCollector) user(login string) *github.User {
+	key := strings.ToLower(login)
+	ghc.resolver.Lock()
+	user, ok := ghc.resolver.users[key]
+	ghc.resolver.Unlock()
+	if ok {
+		return user
+	}
+	// Fall back to the legacy noreply address which only needs the login
+	fallback := &github.User{Login: github.String(login)}
+	if ghc.resolver.limited(timeNow()) {
+		return fallback
+	}
+	user, _, err := ghc.client.Users.Get(ghc.ctx, login)
+	if err != nil {
+		if !ghc.resolver.hit(err) {
+			logrus.Warnf("Unable to resolve member %v: %v", login, err)
+		}
+		return fallback
+	}
+	ghc.resolver.Lock()
+	ghc.resolver.users[key] = user
+	ghc.resolver.Unlock()
+	return user
+}
+
+//repoAuthors returns the email addresses the logins of the recent commits of a repo authored them with, by lower case
+//login.  Only the most recent page of commits is inspected, and only again once the repo is pushed to.
+func (ghc *GitHubCloneCollector) repoAuthors(repo *github.Repository) map[string][]string {
+	key := cacheKey(repo)
+	pushed := repo.GetPushedAt().Time
+	ghc.resolver.Lock()
+	ra, ok := ghc.resolver.authors[key]
+	ghc.resolver.Unlock()
+	if ok && !pushed.After(ra.pushed) {
+		return ra.emails
+	}
+	// Stale authors are better than none
+	var stale map[string][]string
+	if ok {
+		stale = ra.emails
+	}
+	if ghc.resolver.limited(timeNow()) {
+		return stale
+	}
+	commits, _, err := ghc.client.Repositories.ListCommits(ghc.ctx, repo.GetOwner().GetLogin(), repo.GetName(), &github.CommitsListOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		if !ghc.resolver.hit(err) {
+			logrus.Warnf("Unable to list commits of %v: %v", repo.GetFullName(), err)
+		}
+		return stale
+	}
+	emails := make(map[string][]string)
+	for _, commit := range commits {
+		login := strings.ToLower(commit.GetAuthor().GetLogin())
+		email := commit.GetCommit().GetAuthor().GetEmail()
+		if login != "" && email != "" {
+			emails[login] = append(emails[login], email)
+		}
+	}
+	ghc.resolver.Lock()
+	ghc.resolver.authors[key] = &repoAuthors{pushed: pushed, emails: emails}
+	ghc.resolver.Unlock()
+	return emails
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func Test_splitMembers(t *testing.T) {
+	tests := []struct {
+		name       string
+		members    []string
+		wantEmails []string
+		wantLogins []string
+	}{
+		{
+			name:       "Mixed",
+			members:    []string{"jane@example.com", "jdoe", ""},
+			wantEmails: []string{"jane@example.com"},
+			wantLogins: []string{"jdoe"},
+		}, {
+			name: "Empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotEmails, gotLogins := splitMembers(tt.members)
+			if !reflect.DeepEqual(gotEmails, tt.wantEmails) {
+				t.Errorf("splitMembers() gotEmails = %v, want %v", gotEmails, tt.wantEmails)
+			}
+			if !reflect.DeepEqual(gotLogins, tt.wantLogins) {
+				t.Errorf("splitMembers() gotLogins = %v, want %v", gotLogins, tt.wantLogins)
+			}
+		})
+	}
+}
+
+func Test_noreplyEmails(t *testing.T) {
+	tests := []struct {
+		name  string
+		login string
+		id    int64
+		want  []string
+	}{
+		{
+			name:  "With ID",
+			login: "jdoe",
+			id:    1234,
+			want:  []string{"jdoe@users.noreply.github.com", "1234+jdoe@users.noreply.github.com"},
+		}, {
+			name:  "Without ID",
+			login: "jdoe",
+			want:  []string{"jdoe@users.noreply.github.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := noreplyEmails(tt.login, tt.id); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("noreplyEmails() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_identities_list(t *testing.T) {
+	id := newIdentities()
+	id.add("Jane@Example.com", "", "jdoe@users.noreply.github.com", "jane@example.com")
+	want := []string{"jane@example.com", "jdoe@users.noreply.github.com"}
+	if got := id.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("identities.list() = %v, want %v", got, want)
+	}
+}
+
+func Test_identities_aliases(t *testing.T) {
+	id := newIdentities()
+	id.add("jane@example.com")
+	id.addFor("JDoe", "1234+JDoe@users.noreply.github.com", "")
+	want := map[string]string{"1234+jdoe@users.noreply.github.com": "jdoe"}
+	if got := id.aliases(); !reflect.DeepEqual(got, want) {
+		t.Errorf("identities.aliases() = %v, want %v", got, want)
+	}
+	if got := id.list(); len(got) != 2 {
+		t.Errorf("identities.list() = %v, want 2 emails", got)
+	}
+}
+
+func TestGitHubCloneCollector_resolveIdentities(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	ghc := NewGitHubCloneCollector(constants, testCache)
+	ghc.constants.Members = []string{"jdoe@example.com"}
+	repo, _, err := ghc.client.Repositories.Get(ghc.ctx, "unorepo", "uno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := []*github.Repository{repo}
+	requests := testGitHub.Requests()
+	ids := ghc.resolveIdentities(repos)
+	want := []string{"2+janedoe@users.noreply.github.com", "jane.doe@example.com", "janedoe@users.noreply.github.com",
+		"jdoe@example.com"}
+	if got := ids.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() = %v, want %v", got, want)
+	}
+	if got := ids.aliases()["jane.doe@example.com"]; got != "janedoe" {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() alias = %v, want janedoe", got)
+	}
+	// The members, the user, and one page of commits of the repo
+	if got := testGitHub.Requests() - requests; got != 3 {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() made %d requests, want 3", got)
+	}
+	// Lookups are memoized until the repo is pushed to, only the members are listed again
+	requests = testGitHub.Requests()
+	if got := ghc.resolveIdentities(repos).list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() again = %v, want %v", got, want)
+	}
+	if got := testGitHub.Requests() - requests; got != 1 {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() again made %d requests, want 1", got)
+	}
+	// Members that are gone no longer match
+	ghc.constants.Token = ""
+	if got := ghc.resolveIdentities(repos).list(); !reflect.DeepEqual(got, []string{"jdoe@example.com"}) {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() without members = %v, want jdoe@example.com", got)
+	}
+}
+
+func TestGitHubCloneCollector_resolveIdentities_rateLimit(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	ghc := NewGitHubCloneCollector(constants, testCache)
+	ghc.constants.Members = []string{"janedoe"}
+	repos := []*github.Repository{testRepo("unorepo", "uno")}
+	testGitHub.SetRateLimit(0)
+	ids := ghc.resolveIdentities(repos)
+	if !ghc.resolver.limited(timeNow()) {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() didn't stop at the rate limit")
+	}
+	// Only the legacy noreply address is known without the user
+	want := []string{"janedoe@users.noreply.github.com"}
+	if got := ids.list(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() = %v, want %v", got, want)
+	}
+	requests := testGitHub.Requests()
+	ghc.resolveIdentities(repos)
+	if got := testGitHub.Requests() - requests; got != 0 {
+		t.Errorf("GitHubCloneCollector.resolveIdentities() made %d requests while rate limited, want none", got)
+	}
+}
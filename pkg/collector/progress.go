@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+//Progress is how far a collection in progress has got.  Done counts the repos collected or failed so far, out of the
+//Total being collected.  Current is the repo the collection most recently moved on to, as repos are collected
+//concurrently.  ETA is estimated from the pace so far, once a repo is done.
+type Progress struct {
+	Started time.Time  `json:"started"`
+	Done    int        `json:"done"`
+	Total   int        `json:"total"`
+	Current string     `json:"current,omitempty"`
+	ETA     *time.Time `json:"eta,omitempty"`
+}
+
+//collection records the results of a collection in progress as each repo finishes, so they can be reported meanwhile
+type collection struct {
+	mu       sync.Mutex
+	w        Window
+	started  time.Time
+	total    int
+	done     int
+	current  string
+	repos    []*RepoResults
+	failures []*Failure
+	large    []*LargeRepo
+}
+
+//newCollection returns a collection of total repos within a window, started now
+func newCollection(w Window, total int, now time.Time) *collection {
+	return &collection{w: w, total: total, started: now}
+}
+
+//start records that the collection moved on to a repo
+func (c *collection) start(repo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = repo
+}
+
+//add records the results of a finished repo, nil when it has none, and its large repo entry if any.  They're
+//copied, as the collection goes on with them.
+func (c *collection) add(r *RepoResults, large *LargeRepo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done = c.done + 1
+	if r != nil {
+		rc := *r
+		c.repos = append(c.repos, &rc)
+	}
+	if large != nil {
+		lc := *large
+		c.large = append(c.large, &lc)
+	}
+}
+
+//fail records a failure, of a repo being collected when counted is set, or one skipped otherwise
+func (c *collection) fail(f *Failure, counted bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if counted {
+		c.done = c.done + 1
+	}
+	fc := *f
+	c.failures = append(c.failures, &fc)
+}
+
+//report returns a new report of the results so far, with the progress of the collection at now
+func (c *collection) report(now time.Time) (stats *CollectReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats = newCollectReport(c.w)
+	for _, r := range c.repos {
+		stats.add(r)
+	}
+	for _, f := range c.failures {
+		stats.addFailure(f)
+	}
+	for _, l := range c.large {
+		stats.addLarge(l)
+	}
+	// The collection isn't over
+	stats.Complete = false
+	stats.Progress = &Progress{
+		Started: c.started,
+		Done:    c.done,
+		Total:   c.total,
+		Current: c.current,
+	}
+	if c.done > 0 && c.done < c.total {
+		elapsed := now.Sub(c.started)
+		eta := now.Add(elapsed / time.Duration(c.done) * time.Duration(c.total-c.done))
+		stats.Progress.ETA = &eta
+	}
+	return
+}
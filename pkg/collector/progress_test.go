@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thales-e-security/contribstats/pkg/cache"
+)
+
+func Test_collection_report(t *testing.T) {
+	started := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newCollection(Window{}, 4, started)
+	c.fail(&Failure{Repo: "github.com/unorepo/skipped", Skipped: true}, false)
+	if got := c.report(started).Progress; got.Done != 0 || got.Total != 4 || got.ETA != nil {
+		t.Errorf("collection.report() progress = %+v, want none done of 4 without an ETA", got)
+	}
+	c.start("github.com/unorepo/uno")
+	r := &RepoResults{Repo: "github.com/unorepo/uno", Commits: 2, Additions: 3, Deletions: 1, Lines: 4,
+		contributors: cache.Breakdown{"jdoe": {Commits: 2, Additions: 3, Deletions: 1}}}
+	c.add(r, nil)
+	c.start("github.com/unorepo/dos")
+	// The results so far are copies, unaffected by the rest of the collection
+	r.CacheSize = 1024
+	got := c.report(started.Add(time.Hour))
+	if got.Complete || got.Commits != 2 || got.Lines != 4 || got.Projects != 1 || len(got.Failures) != 1 {
+		t.Errorf("collection.report() = %+v, want the incomplete results of uno and a failure", got)
+	}
+	if got.Repos[0].CacheSize != 0 {
+		t.Errorf("collection.report() shares the results of the collection")
+	}
+	if len(got.Contributors) != 1 || got.Contributors[0].Commits != 2 {
+		t.Errorf("collection.report() contributors = %v, want jdoe", got.Contributors)
+	}
+	wantETA := started.Add(4 * time.Hour)
+	p := got.Progress
+	if p.Done != 1 || p.Total != 4 || p.Current != "github.com/unorepo/dos" || !p.Started.Equal(started) ||
+		p.ETA == nil || !p.ETA.Equal(wantETA) {
+		t.Errorf("collection.report() progress = %+v, want 1 done of 4 at dos, ETA %v", p, wantETA)
+	}
+	c.fail(&Failure{Repo: "github.com/unorepo/dos"}, true)
+	c.add(nil, &LargeRepo{Repo: "github.com/unorepo/tres", Policy: LargeSkip})
+	c.add(&RepoResults{Repo: "github.com/unorepo/cuatro", Commits: 1}, nil)
+	got = c.report(started.Add(2 * time.Hour))
+	if got.Commits != 3 || got.Projects != 2 || len(got.Failures) != 2 || len(got.Large) != 1 {
+		t.Errorf("collection.report() = %+v, want the results of uno and cuatro, 2 failures and a large repo", got)
+	}
+	if p := got.Progress; p.Done != 4 || p.ETA != nil {
+		t.Errorf("collection.report() progress = %+v, want all done without an ETA", p)
+	}
+}
+
+func TestGitHubCloneCollector_Progress(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	wait := make(chan struct{})
+	ghc := NewGitHubCloneCollector(constants, &MockCache{wait: wait})
+	if got := ghc.Progress(); got != nil {
+		t.Fatalf("GitHubCloneCollector.Progress() = %v before collecting, want nil", got)
+	}
+	done := make(chan *CollectReport)
+	go func() {
+		stats, err := ghc.Collect()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- stats
+	}()
+	// The clone of uno is held up until wait is closed
+	var got *CollectReport
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if got = ghc.Progress(); got != nil && got.Progress.Current != "" {
+			break
+		}
+	}
+	close(wait)
+	stats := <-done
+	if got == nil {
+		t.Fatal("GitHubCloneCollector.Progress() = nil while collecting")
+	}
+	if p := got.Progress; p.Done != 0 || p.Total != 1 || p.Current != "github.com/unorepo/uno" || got.Complete {
+		t.Errorf("GitHubCloneCollector.Progress() progress = %+v, want uno in progress", p)
+	}
+	if stats.Progress != nil || stats.Commits != 1 {
+		t.Errorf("GitHubCloneCollector.Collect() = %+v, want the results of uno without progress", stats)
+	}
+	if got := ghc.Progress(); got != nil {
+		t.Errorf("GitHubCloneCollector.Progress() = %v after collecting, want nil", got)
+	}
+}
@@ -0,0 +1,267 @@
+package collector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/thales-e-security/contribstats/pkg/cache"
+)
+
+//RepoResults contains results from an individual repository, counted like cache.RepoStats
+type RepoResults struct {
+	Repo         string          `json:"repo"`
+	Organization string          `json:"organization,omitempty"`
+	Commits      int64           `json:"commits"`
+	Additions    int64           `json:"additions"`
+	Deletions    int64           `json:"deletions"`
+	Lines        int64           `json:"lines"`
+	Excluded     int64           `json:"excluded"`
+	Bots         int64           `json:"bots"`
+	Series       cache.Series    `json:"series,omitempty"`
+	Languages    cache.Languages `json:"languages,omitempty"`
+	Domains      cache.Breakdown `json:"domains,omitempty"`
+	// CacheSize is the disk usage of the repo in the cache, in bytes
+	CacheSize int64 `json:"cachesize,omitempty"`
+	// contributors are only reported through CollectReport.Contributors
+	contributors cache.Breakdown
+	// key is the cache key of the repo
+	key string
+	// large is set when the repo is over the size limits
+	large *LargeRepo
+	// collected is when the results were computed
+	collected time.Time
+}
+
+//newRepoResults returns the results of a repo from its cached stats
+func newRepoResults(repo *github.Repository, rs *cache.RepoStats) *RepoResults {
+	return &RepoResults{
+		Repo:         repoName(repo),
+		Organization: repo.GetOwner().GetLogin(),
+		Commits:      rs.Commits,
+		Additions:    rs.Additions,
+		Deletions:    rs.Deletions,
+		Lines:        rs.Lines,
+		Excluded:     rs.Excluded,
+		Bots:         rs.Bots,
+		Series:       rs.Series,
+		Languages:    rs.Languages,
+		Domains:      rs.Domains,
+
+		contributors: rs.Contributors,
+		key:          cacheKey(repo),
+	}
+}
+
+//CollectReport contains the results of an entire collection of repos, and an aggregated value of each stats, counted
+//like cache.RepoStats.
+//Complete is false when some repos failed, as listed in Failures, so the totals leave them out.
+//Progress is only set on the partial results of a collection in progress, see Collector.Progress.
+//Generation and Published are set when a report is published to be served, each report published being the next
+//generation.
+type CollectReport struct {
+	Repos        []*RepoResults  `json:"repos,omitempty"`
+	Commits      int64           `json:"commits"`
+	Additions    int64           `json:"additions"`
+	Deletions    int64           `json:"deletions"`
+	Lines        int64           `json:"lines"`
+	Excluded     int64           `json:"excluded"`
+	Bots         int64           `json:"bots"`
+	Projects     int64           `json:"projects"`
+	Complete     bool            `json:"complete"`
+	Since        *time.Time      `json:"since,omitempty"`
+	Until        *time.Time      `json:"until,omitempty"`
+	Series       cache.Series    `json:"series,omitempty"`
+	Languages    cache.Languages `json:"languages,omitempty"`
+	Contributors []*Contributor  `json:"contributors,omitempty"`
+	// Domains break the report down by matched email domain, commits only matched by member aren't part of any
+	Domains map[string]*Share `json:"domains,omitempty"`
+	// Organizations break the report down by the organization, or owner, of the repos
+	Organizations map[string]*Share `json:"organizations,omitempty"`
+	// CacheSize is the disk usage of the whole cache, in bytes
+	CacheSize int64 `json:"cachesize,omitempty"`
+	// Failures are the repos that couldn't be collected
+	Failures []*Failure `json:"failures,omitempty"`
+	// Large are the repos over the size limits, which are left out, only partly analyzed, or analyzed in the
+	// background, see LargeRepo
+	Large    []*LargeRepo `json:"large,omitempty"`
+	Progress *Progress    `json:"progress,omitempty"`
+	// Collecting is the partial results of the collection in progress, served alongside the last complete report
+	Collecting *CollectReport `json:"collecting,omitempty"`
+	Generation int64          `json:"generation,omitempty"`
+	Published  *time.Time     `json:"published,omitempty"`
+}
+
+//Failure is a repo that couldn't be collected, at a stage and for a kind of reason, see RepoError.
+//Failing repos are retried with an exponential backoff, and quarantined after enough failures in a row until they're
+//pushed to.  Meanwhile they're skipped, and their last failure is reported.
+type Failure struct {
+	Repo    string `json:"repo"`
+	Stage   string `json:"stage"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Failures is the number of failures in a row
+	Failures int `json:"failures,omitempty"`
+	// Retry is when the repo is next collected, unless it's quarantined
+	Retry       *time.Time `json:"retry,omitempty"`
+	Quarantined bool       `json:"quarantined,omitempty"`
+	// Skipped is set when the repo wasn't collected this time, as it's waiting to be retried or quarantined
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+//Share contains the results of a part of a report, e.g. a domain or an organization
+type Share struct {
+	Projects  int64 `json:"projects,omitempty"`
+	Commits   int64 `json:"commits"`
+	Additions int64 `json:"additions"`
+	Deletions int64 `json:"deletions"`
+	Lines     int64 `json:"lines"`
+}
+
+//addShare adds counts to the share of key, allocating the shares if nil, and returns the resulting shares
+func addShare(shares map[string]*Share, key string, commits, additions, deletions int64) (map[string]*Share, *Share) {
+	if shares == nil {
+		shares = make(map[string]*Share)
+	}
+	s, ok := shares[key]
+	if !ok {
+		s = &Share{}
+		shares[key] = s
+	}
+	s.Commits = s.Commits + commits
+	s.Additions = s.Additions + additions
+	s.Deletions = s.Deletions + deletions
+	s.Lines = s.Additions + s.Deletions
+	return shares, s
+}
+
+//Contributor contains the results of a single identity, in total and per repository
+type Contributor struct {
+	Identity  string             `json:"identity"`
+	Commits   int64              `json:"commits"`
+	Additions int64              `json:"additions"`
+	Deletions int64              `json:"deletions"`
+	Lines     int64              `json:"lines"`
+	Repos     []*ContributorRepo `json:"repos"`
+}
+
+//ContributorRepo contains the results of a single identity in a repository
+type ContributorRepo struct {
+	Repo      string `json:"repo"`
+	Commits   int64  `json:"commits"`
+	Additions int64  `json:"additions"`
+	Deletions int64  `json:"deletions"`
+	Lines     int64  `json:"lines"`
+}
+
+//Window is the time window a report covers, zero values are unbounded
+type Window struct {
+	Since time.Time
+	Until time.Time
+}
+
+//newCollectReport returns an empty report that echoes the window it covers
+func newCollectReport(w Window) (stats *CollectReport) {
+	stats = &CollectReport{Complete: true}
+	if !w.Since.IsZero() {
+		stats.Since = &w.Since
+	}
+	if !w.Until.IsZero() {
+		stats.Until = &w.Until
+	}
+	return
+}
+
+//add aggregates the results of a repo into the report
+func (stats *CollectReport) add(r *RepoResults) {
+	stats.Repos = append(stats.Repos, r)
+	stats.Commits = stats.Commits + r.Commits
+	stats.Additions = stats.Additions + r.Additions
+	stats.Deletions = stats.Deletions + r.Deletions
+	stats.Lines = stats.Additions + stats.Deletions
+	stats.Excluded = stats.Excluded + r.Excluded
+	stats.Bots = stats.Bots + r.Bots
+	stats.Series = stats.Series.Merge(r.Series)
+	stats.Languages = stats.Languages.Merge(r.Languages)
+	stats.addContributors(r)
+	for domain, c := range r.Domains {
+		stats.Domains, _ = addShare(stats.Domains, domain, c.Commits, c.Additions, c.Deletions)
+	}
+	var org *Share
+	stats.Organizations, org = addShare(stats.Organizations, r.Organization, r.Commits, r.Additions, r.Deletions)
+	org.Projects = org.Projects + 1
+	// For convenience, keep a count of repos
+	stats.Projects = int64(len(stats.Repos))
+}
+
+//fail records the failure of a repo, which leaves the report incomplete, and returns it
+func (stats *CollectReport) fail(err error) *Failure {
+	re, ok := err.(*RepoError)
+	if !ok {
+		re = newRepoError("", StageCollect, err)
+	}
+	f := &Failure{
+		Repo:    re.Repo,
+		Stage:   re.Stage,
+		Kind:    re.Kind,
+		Message: re.Err.Error(),
+	}
+	stats.addFailure(f)
+	return f
+}
+
+//addFailure adds a failure to the report, which leaves it incomplete
+func (stats *CollectReport) addFailure(f *Failure) {
+	stats.Failures = append(stats.Failures, f)
+	stats.Complete = false
+}
+
+//addContributors aggregates the contributors of a repo into the report, keeping the most active contributors first
+func (stats *CollectReport) addContributors(r *RepoResults) {
+	if len(r.contributors) == 0 {
+		return
+	}
+	for identity, cs := range r.contributors {
+		var c *Contributor
+		for _, existing := range stats.Contributors {
+			if existing.Identity == identity {
+				c = existing
+				break
+			}
+		}
+		if c == nil {
+			c = &Contributor{Identity: identity}
+			stats.Contributors = append(stats.Contributors, c)
+		}
+		c.Commits = c.Commits + cs.Commits
+		c.Additions = c.Additions + cs.Additions
+		c.Deletions = c.Deletions + cs.Deletions
+		c.Lines = c.Additions + c.Deletions
+		c.Repos = append(c.Repos, &ContributorRepo{
+			Repo:      r.Repo,
+			Commits:   cs.Commits,
+			Additions: cs.Additions,
+			Deletions: cs.Deletions,
+			Lines:     cs.Additions + cs.Deletions,
+		})
+	}
+	sort.SliceStable(stats.Contributors, func(i, j int) bool {
+		if stats.Contributors[i].Commits != stats.Contributors[j].Commits {
+			return stats.Contributors[i].Commits > stats.Contributors[j].Commits
+		}
+		return stats.Contributors[i].Identity < stats.Contributors[j].Identity
+	})
+}
+
+//addUsage sets the cache sizes of the report and its repos from the cache usage
+func (stats *CollectReport) addUsage(usage []*cache.RepoUsage) {
+	sizes := make(map[string]int64)
+	stats.CacheSize = 0
+	for _, ru := range usage {
+		sizes[ru.Repo] = ru.Size
+		stats.CacheSize = stats.CacheSize + ru.Size
+	}
+	for _, r := range stats.Repos {
+		r.CacheSize = sizes[r.key]
+	}
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/thales-e-security/contribstats/pkg/cache"
+)
+
+func Test_newCollectReport(t *testing.T) {
+	since := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		w         Window
+		wantSince *time.Time
+	}{
+		{
+			name: "Unbounded",
+		}, {
+			name:      "Since",
+			w:         Window{Since: since},
+			wantSince: &since,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newCollectReport(tt.w)
+			if !reflect.DeepEqual(got.Since, tt.wantSince) || got.Until != nil {
+				t.Errorf("newCollectReport() window = %v - %v, want %v", got.Since, got.Until, tt.wantSince)
+			}
+		})
+	}
+}
+
+func TestCollectReport_add(t *testing.T) {
+	stats := newCollectReport(Window{})
+	stats.add(newRepoResults(testRepo("unorepo", "uno"), &cache.RepoStats{
+		Commits:   3,
+		Additions: 10,
+		Deletions: 2,
+		Lines:     12,
+		Bots:      1,
+		Contributors: cache.Breakdown{
+			"jdoe":             {Commits: 1, Additions: 1},
+			"jane@example.com": {Commits: 2, Additions: 9, Deletions: 2},
+		},
+		Domains: cache.Breakdown{
+			"thalesesec.net": {Commits: 2, Additions: 9, Deletions: 2},
+		},
+	}))
+	stats.add(newRepoResults(testRepo("thales-e-security", "dos"), &cache.RepoStats{
+		Commits:   2,
+		Additions: 4,
+		Lines:     4,
+		Contributors: cache.Breakdown{
+			"jdoe": {Commits: 2, Additions: 4},
+		},
+		Domains: cache.Breakdown{
+			"thalesesec.net":        {Commits: 1, Additions: 1},
+			"thales-e-security.com": {Commits: 1, Additions: 3},
+		},
+	}))
+	if stats.Commits != 5 || stats.Lines != 16 || stats.Projects != 2 || stats.Bots != 1 {
+		t.Errorf("CollectReport.add() commits = %v, lines = %v, projects = %v, bots = %v", stats.Commits, stats.Lines, stats.Projects, stats.Bots)
+	}
+	want := []*Contributor{
+		{
+			Identity:  "jdoe",
+			Commits:   3,
+			Additions: 5,
+			Lines:     5,
+			Repos: []*ContributorRepo{
+				{Repo: "github.com/unorepo/uno", Commits: 1, Additions: 1, Lines: 1},
+				{Repo: "github.com/thales-e-security/dos", Commits: 2, Additions: 4, Lines: 4},
+			},
+		}, {
+			Identity:  "jane@example.com",
+			Commits:   2,
+			Additions: 9,
+			Deletions: 2,
+			Lines:     11,
+			Repos: []*ContributorRepo{
+				{Repo: "github.com/unorepo/uno", Commits: 2, Additions: 9, Deletions: 2, Lines: 11},
+			},
+		},
+	}
+	if !reflect.DeepEqual(stats.Contributors, want) {
+		t.Errorf("CollectReport.add() contributors = %+v, want %+v", stats.Contributors, want)
+	}
+	wantDomains := map[string]*Share{
+		"thalesesec.net":        {Commits: 3, Additions: 10, Deletions: 2, Lines: 12},
+		"thales-e-security.com": {Commits: 1, Additions: 3, Lines: 3},
+	}
+	if !reflect.DeepEqual(stats.Domains, wantDomains) {
+		t.Errorf("CollectReport.add() domains = %+v, want %+v", stats.Domains, wantDomains)
+	}
+	wantOrganizations := map[string]*Share{
+		"unorepo":           {Projects: 1, Commits: 3, Additions: 10, Deletions: 2, Lines: 12},
+		"thales-e-security": {Projects: 1, Commits: 2, Additions: 4, Lines: 4},
+	}
+	if !reflect.DeepEqual(stats.Organizations, wantOrganizations) {
+		t.Errorf("CollectReport.add() organizations = %+v, want %+v", stats.Organizations, wantOrganizations)
+	}
+}
+
+func TestCollectReport_addUsage(t *testing.T) {
+	stats := newCollectReport(Window{})
+	stats.add(newRepoResults(testRepo("unorepo", "uno"), &cache.RepoStats{}))
+	stats.add(newRepoResults(testRepo("unorepo", "dos"), &cache.RepoStats{}))
+	stats.addUsage([]*cache.RepoUsage{
+		{Repo: "github.com/unorepo/uno", Size: 300},
+		{Repo: "github.com/unorepo/tres", Size: 200},
+	})
+	if stats.CacheSize != 500 {
+		t.Errorf("CollectReport.addUsage() cache size = %v, want 500", stats.CacheSize)
+	}
+	if stats.Repos[0].CacheSize != 300 || stats.Repos[1].CacheSize != 0 {
+		t.Errorf("CollectReport.addUsage() repo cache sizes = %v, %v, want 300, 0", stats.Repos[0].CacheSize, stats.Repos[1].CacheSize)
+	}
+}
+
+func testRepo(owner, name string) *github.Repository {
+	return &github.Repository{
+		Owner:    &github.User{Login: github.String(owner)},
+		Name:     github.String(name),
+		FullName: github.String(owner + "/" + name),
+	}
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/sirupsen/logrus"
+)
+
+//DefaultQuarantineAfter is the number of consecutive failures after which a repo is quarantined
+const DefaultQuarantineAfter = 5
+
+//maxRetryBackoff caps the time between attempts at a failing repo
+const maxRetryBackoff = 24 * time.Hour
+
+//retries tracks the consecutive failures of repos across collections.  A failing repo is skipped for exponentially
+//longer, starting from base, and quarantined after enough failures in a row, until its upstream is pushed to.  Repos
+//are keyed like the cache, so their failures follow them across renames and transfers.
+type retries struct {
+	sync.Mutex
+	base  time.Duration
+	after int
+	repos map[string]*retry
+}
+
+//retry is the failure state of a repo
+type retry struct {
+	// last is the last failure of the repo
+	last Failure
+	// pushed is when the upstream was last pushed to as of the last failure, a later push releases the repo
+	pushed time.Time
+}
+
+func newRetries(base time.Duration, after int) *retries {
+	if base <= 0 {
+		base = time.Minute
+	}
+	if after <= 0 {
+		after = DefaultQuarantineAfter
+	}
+	return &retries{
+		base:  base,
+		after: after,
+		repos: make(map[string]*retry),
+	}
+}
+
+//skip returns the failure to report for a repo that isn't to be collected now, or nil to collect it
+func (r *retries) skip(repo *github.Repository, now time.Time) *Failure {
+	r.Lock()
+	defer r.Unlock()
+	key := cacheKey(repo)
+	s, ok := r.repos[key]
+	if !ok {
+		return nil
+	}
+	name := repoName(repo)
+	if pushed := repo.GetPushedAt().Time; pushed.After(s.pushed) {
+		if s.last.Quarantined {
+			logrus.Infof("Releasing %v from quarantine, it was pushed to", name)
+		}
+		delete(r.repos, key)
+		return nil
+	}
+	if !s.last.Quarantined && !now.Before(*s.last.Retry) {
+		return nil
+	}
+	f := s.last
+	// The repo may have been renamed since it failed
+	f.Repo = name
+	f.Skipped = true
+	return &f
+}
+
+//fail records a failure of a repo, and sets when it's retried, or that it's quarantined, on the failure
+func (r *retries) fail(repo *github.Repository, f *Failure, now time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	name := repoName(repo)
+	key := cacheKey(repo)
+	s, ok := r.repos[key]
+	if !ok {
+		s = &retry{}
+		r.repos[key] = s
+	}
+	f.Failures = s.last.Failures + 1
+	if f.Failures >= r.after {
+		f.Quarantined = true
+		f.Retry = nil
+		logrus.Warnf("Quarantined %v after %d failures in a row, until it's pushed to", name, f.Failures)
+	} else {
+		backoff := r.base << uint(f.Failures-1)
+		if backoff > maxRetryBackoff || backoff <= 0 {
+			backoff = maxRetryBackoff
+		}
+		retry := now.Add(backoff)
+		f.Retry = &retry
+	}
+	s.last = *f
+	s.pushed = repo.GetPushedAt().Time
+}
+
+//succeed forgets the failures of a repo
+func (r *retries) succeed(repo *github.Repository) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.repos, cacheKey(repo))
+}
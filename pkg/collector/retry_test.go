@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func Test_retries(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := testRepo("unorepo", "uno")
+	repo.PushedAt = &github.Timestamp{Time: now.Add(-time.Hour)}
+	r := newRetries(time.Minute, 3)
+	fail := func(at time.Time) *Failure {
+		f := &Failure{Repo: repoName(repo), Stage: StageClone, Kind: FailureClone, Message: "broken"}
+		r.fail(repo, f, at)
+		return f
+	}
+	type step struct {
+		name            string
+		at              time.Duration
+		fail            bool
+		pushed          bool
+		wantSkip        bool
+		wantRetry       time.Duration
+		wantQuarantined bool
+	}
+	steps := []step{
+		{name: "New", wantSkip: false},
+		{name: "First Failure", fail: true, wantRetry: time.Minute},
+		{name: "Backing Off", at: 30 * time.Second, wantSkip: true},
+		{name: "Retry", at: time.Minute, wantSkip: false},
+		{name: "Second Failure", at: time.Minute, fail: true, wantRetry: 3 * time.Minute},
+		{name: "Backing Off Longer", at: 2 * time.Minute, wantSkip: true},
+		{name: "Third Failure", at: 3 * time.Minute, fail: true, wantQuarantined: true},
+		{name: "Quarantined", at: 48 * time.Hour, wantSkip: true},
+		{name: "Pushed", at: 48 * time.Hour, pushed: true, wantSkip: false},
+		{name: "Failing Again", at: 48 * time.Hour, fail: true, wantRetry: 48*time.Hour + time.Minute},
+	}
+	for _, s := range steps {
+		at := now.Add(s.at)
+		if s.pushed {
+			repo.PushedAt = &github.Timestamp{Time: at}
+		}
+		if s.fail {
+			f := fail(at)
+			if f.Quarantined != s.wantQuarantined {
+				t.Errorf("%s: retries.fail() quarantined = %v, want %v", s.name, f.Quarantined, s.wantQuarantined)
+			}
+			if s.wantQuarantined && f.Retry != nil {
+				t.Errorf("%s: retries.fail() retry = %v, want none", s.name, f.Retry)
+			}
+			if !s.wantQuarantined && (f.Retry == nil || !f.Retry.Equal(now.Add(s.wantRetry))) {
+				t.Errorf("%s: retries.fail() retry = %v, want %v", s.name, f.Retry, now.Add(s.wantRetry))
+			}
+			continue
+		}
+		f := r.skip(repo, at)
+		if (f != nil) != s.wantSkip {
+			t.Errorf("%s: retries.skip() = %+v, want skip %v", s.name, f, s.wantSkip)
+			continue
+		}
+		if f != nil && (!f.Skipped || f.Message != "broken" || f.Failures == 0) {
+			t.Errorf("%s: retries.skip() = %+v, want the last failure", s.name, f)
+		}
+	}
+	r.succeed(repo)
+	if f := r.skip(repo, now.Add(48*time.Hour)); f != nil {
+		t.Errorf("retries.skip() after success = %+v, want nil", f)
+	}
+}
+
+func Test_retries_renamed(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := testRepo("unorepo", "uno")
+	repo.ID = github.Int64(1234)
+	r := newRetries(time.Minute, 3)
+	r.fail(repo, &Failure{Repo: repoName(repo), Kind: FailureClone}, now)
+	// A renamed repo keeps its failures, reported under its new name
+	renamed := testRepo("unorepo", "dos")
+	renamed.ID = github.Int64(1234)
+	f := r.skip(renamed, now)
+	if f == nil || f.Repo != repoName(renamed) || f.Failures != 1 {
+		t.Fatalf("retries.skip() of the renamed repo = %+v, want its failure as %v", f, repoName(renamed))
+	}
+	r.succeed(renamed)
+	if f := r.skip(repo, now); f != nil {
+		t.Errorf("retries.skip() after the renamed repo succeeded = %+v, want nil", f)
+	}
+}
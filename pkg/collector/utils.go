@@ -10,7 +10,8 @@ import (
 	"net/http"
 )
 
-//NewV3Client returns an authenticated or anonymous GitHub v3 client
+//NewV3Client returns an authenticated or anonymous GitHub v3 client, of api.github.com unless the GitHubURL says
+//otherwise
 func NewV3Client(constants config.Config) (client *github.Client, ctx context.Context) {
 	ctx = context.Background()
 	var tc *http.Client
@@ -26,5 +27,14 @@ func NewV3Client(constants config.Config) (client *github.Client, ctx context.Co
 		logrus.Warnf("Try adding token to your config at: %v", viper.ConfigFileUsed())
 	}
 	client = github.NewClient(tc)
+	if constants.GitHubURL != "" {
+		// GitHub Enterprise serves uploads from the same URL
+		enterprise, err := github.NewEnterpriseClient(constants.GitHubURL, constants.GitHubURL, tc)
+		if err != nil {
+			logrus.Errorf("Invalid GitHub URL %v, using api.github.com: %s", constants.GitHubURL, err)
+			return
+		}
+		client = enterprise
+	}
 	return
 }
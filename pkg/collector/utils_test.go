@@ -23,6 +23,7 @@ func TestNewV3Client(t *testing.T) {
 		wantClient bool
 		wantAuth   bool
 		wantCtx    bool
+		wantURL    string
 		constants  config.Config
 	}{
 		{
@@ -30,6 +31,7 @@ func TestNewV3Client(t *testing.T) {
 			wantClient: true,
 			wantAuth:   false,
 			wantCtx:    true,
+			wantURL:    "https://api.github.com/",
 			constants:  config.Config{},
 		}, {
 			name:       "Token",
@@ -37,6 +39,18 @@ func TestNewV3Client(t *testing.T) {
 			wantAuth:   true,
 			wantCtx:    true,
 			constants:  config.Config{},
+		}, {
+			name:       "Enterprise",
+			wantClient: true,
+			wantCtx:    true,
+			wantURL:    "https://github.example.com/api/v3/",
+			constants:  config.Config{GitHubURL: "https://github.example.com/api/v3"},
+		}, {
+			name:       "Bad URL",
+			wantClient: true,
+			wantCtx:    true,
+			wantURL:    "https://api.github.com/",
+			constants:  config.Config{GitHubURL: "://github.example.com"},
 		},
 	}
 	for _, tt := range tests {
@@ -53,6 +67,9 @@ func TestNewV3Client(t *testing.T) {
 				t.Errorf("NewV3Client() gotCtx = %v, want %v", (gotCtx != nil), tt.wantCtx)
 
 			}
+			if tt.wantURL != "" && gotClient.BaseURL.String() != tt.wantURL {
+				t.Errorf("NewV3Client() BaseURL = %v, want %v", gotClient.BaseURL, tt.wantURL)
+			}
 
 		})
 	}
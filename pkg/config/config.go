@@ -13,14 +13,98 @@ var readConfig = viper.ReadInConfig
 
 //Config stores the viper config results after loading for layer passing around
 type Config struct {
-	Interval      int
-	Token         string
+	Interval int
+	Token    string
+	// GitHubURL is the base URL of the GitHub API, e.g. "https://github.example.com/api/v3/" for GitHub Enterprise,
+	// empty is api.github.com
+	GitHubURL     string
 	Cache         string
 	Organizations []string
 	Domains       []string
 	Origins       []string
 	Members       []string
-	Blacklist     []string
+	// Blacklist and Allowlist are patterns of the full names of repos, e.g. "unorepo/*", "linux-*" for any owner, or
+	// "/^unorepo/linux-.*$/" as a regular expression.  Blacklisted repos aren't collected, and when there's an
+	// allowlist only the repos on it are.
+	Blacklist []string
+	Allowlist []string
+	// SkipForks, SkipArchived, SkipDisabled and SkipTemplates leave out forks, archived, disabled and template repos
+	SkipForks     bool
+	SkipArchived  bool
+	SkipDisabled  bool
+	SkipTemplates bool
+	// Topics only collects the repos with any of them, ExcludeTopics leaves out the repos with any of them
+	Topics        []string
+	ExcludeTopics []string
+	// Visibility only collects the repos of a visibility, "public", "private" or "internal", empty is any
+	Visibility string
+	// CloneDepth limits the cached history of repos to that many commits from the tip of each branch, regardless of
+	// Since, as go-git can't clone since a date, zero is unlimited
+	CloneDepth int
+	// MaxRepoSize is the size, in megabytes according to GitHub, and MaxRepoObjects the number of objects once
+	// cached, above which repos are collected as LargeRepos says, zero is unlimited
+	MaxRepoSize    int
+	MaxRepoObjects int
+	// LargeRepos is the policy for repos over the size limits: "skip" (default) leaves them out, "window" only
+	// analyzes their history within the LargeRepoWindow, a period before now that is "1y" by default, and
+	// "background" analyzes them one at a time without holding up collections, which report their last stats
+	LargeRepos      string
+	LargeRepoWindow string
+	// QuarantineAfter is the number of failures in a row after which a repo is no longer collected, until it's
+	// pushed to, zero is the default of 5.  Until then it's retried with an exponential backoff from Interval.
+	QuarantineAfter int
+	// CacheQuota is the disk usage, in megabytes, above which the least recently used repos are evicted from the
+	// cache, zero is unlimited
+	CacheQuota int
+	// Since and Until bound collection to a time window, as dates or periods before now, see ParseTime
+	Since string
+	Until string
+	// WindowDate selects which commit date the window applies to, either "author" (default) or "committer"
+	WindowDate string
+	// Bucket is the period of the time series in reports, either "month" (default) or "week"
+	Bucket string
+	// Include and Exclude are path patterns of the files whose lines count, in addition to the default exclusions
+	// of vendored, generated and lock files unless NoDefaultExcludes is set
+	Include           []string
+	Exclude           []string
+	NoDefaultExcludes bool
+	// Match is the policy for which identity of a commit is matched against Members and Domains, either "committer"
+	// (default), "author", or "either"
+	Match string
+	// Merges is the policy for merge commits, either "skip" (default) or "count" to count them without lines
+	Merges string
+	// Reverts is the policy for revert commits, either "exclude" (default), "net" to also ignore the reverted
+	// commits, or "count" to count them like any other commit
+	Reverts string
+	// Refs select the branches and tags whose history is analysed: "default" (default), "branches", "tags", or
+	// patterns of ref names, e.g. "refs/tags/v*" or "release-*"
+	Refs []string
+	// Bots are patterns of the names and email addresses of bot accounts, whose commits are counted apart, in
+	// addition to the built in ones unless NoDefaultBots is set
+	Bots          []string
+	NoDefaultBots bool
+	// HideContributors leaves the per contributor breakdown out of the stats API, for privacy
+	HideContributors bool
+	// Repositories holds settings for individual repositories, keyed by their full name, e.g. "owner/repo"
+	Repositories map[string]RepoConfig
+}
+
+//RepoConfig stores the settings of an individual repository, which add to the global ones
+type RepoConfig struct {
+	Include []string
+	Exclude []string
+	// Refs replace the global Refs, rather than adding to them
+	Refs []string
+}
+
+//Repository returns the settings of a repository by its full name, ignoring case
+func (c Config) Repository(fullname string) (rc RepoConfig) {
+	for name, r := range c.Repositories {
+		if strings.ToLower(name) == strings.ToLower(fullname) {
+			return r
+		}
+	}
+	return
 }
 
 //InitConfig reads in config file and ENV variables if set.
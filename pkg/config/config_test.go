@@ -6,6 +6,7 @@ import (
 	"github.com/spf13/viper"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -87,3 +88,35 @@ organizations:
 		})
 	}
 }
+
+func TestConfig_Repository(t *testing.T) {
+	c := Config{
+		Repositories: map[string]RepoConfig{
+			"thales-e-security/contribstats": {
+				Exclude: []string{"chart/**"},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		fullname string
+		want     []string
+	}{
+		{
+			name:     "Found",
+			fullname: "Thales-e-Security/ContribStats",
+			want:     []string{"chart/**"},
+		}, {
+			name:     "Missing",
+			fullname: "unorepo/uno",
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Repository(tt.fullname); !reflect.DeepEqual(got.Exclude, tt.want) {
+				t.Errorf("Config.Repository() = %v, want %v", got.Exclude, tt.want)
+			}
+		})
+	}
+}
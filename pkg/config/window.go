@@ -0,0 +1,62 @@
+package config
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var relativeRegexp = regexp.MustCompile(`^(\d+)([dwy])$`)
+
+var relativeUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+//ParseTime parses an absolute date ("2006-01-02" or RFC3339), or a period before now ("90d", "12w", "1y", or a Go duration like "36h").
+//An empty string returns the zero time, which means unbounded.
+func ParseTime(in string, now time.Time) (t time.Time, err error) {
+	if in == "" {
+		return
+	}
+	if t, err = time.Parse("2006-01-02", in); err == nil {
+		return
+	}
+	if t, err = time.Parse(time.RFC3339, in); err == nil {
+		return
+	}
+	if m := relativeRegexp.FindStringSubmatch(in); m != nil {
+		var n int
+		if n, err = strconv.Atoi(m[1]); err != nil {
+			return
+		}
+		t = now.Add(-time.Duration(n) * relativeUnits[m[2]])
+		return
+	}
+	var d time.Duration
+	if d, err = time.ParseDuration(in); err != nil {
+		err = errors.Errorf("invalid time %q, expected a date like 2006-01-02 or a period like 90d", in)
+		return
+	}
+	t = now.Add(-d)
+	return
+}
+
+//ParseWindow parses the since and until values of a time window, see ParseTime.
+func ParseWindow(since, until string, now time.Time) (s time.Time, u time.Time, err error) {
+	if s, err = ParseTime(since, now); err != nil {
+		err = errors.Wrap(err, "since")
+		return
+	}
+	if u, err = ParseTime(until, now); err != nil {
+		err = errors.Wrap(err, "until")
+		return
+	}
+	if !s.IsZero() && !u.IsZero() && !s.Before(u) {
+		err = errors.New("since must be before until")
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2018, 8, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+			in:   "",
+			want: time.Time{},
+		}, {
+			name: "Date",
+			in:   "2018-01-01",
+			want: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, {
+			name: "RFC3339",
+			in:   "2018-01-01T10:00:00Z",
+			want: time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC),
+		}, {
+			name: "Days",
+			in:   "90d",
+			want: now.Add(-90 * 24 * time.Hour),
+		}, {
+			name: "Weeks",
+			in:   "2w",
+			want: now.Add(-14 * 24 * time.Hour),
+		}, {
+			name: "Duration",
+			in:   "36h",
+			want: now.Add(-36 * time.Hour),
+		}, {
+			name:    "Error",
+			in:      "yesterday",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTime(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	now := time.Date(2018, 8, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		since   string
+		until   string
+		wantErr bool
+	}{
+		{
+			name:  "OK",
+			since: "90d",
+			until: "2018-08-01",
+		}, {
+			name:  "Unbounded",
+			since: "",
+			until: "",
+		}, {
+			name:    "Error Since",
+			since:   "bad",
+			wantErr: true,
+		}, {
+			name:    "Error Until",
+			until:   "bad",
+			wantErr: true,
+		}, {
+			name:    "Error Order",
+			since:   "2018-08-01",
+			until:   "2018-01-01",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseWindow(tt.since, tt.until, now); (err != nil) != tt.wantErr {
+				t.Errorf("ParseWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/thales-e-security/contribstats/pkg/config"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -21,7 +22,11 @@ type Server interface {
 
 // StatServer is starts and polls stats collection, and serves the results via a simple API
 type StatServer struct {
+	// mu guards stats, the last complete report.  Published reports are replaced, never modified, so they may be
+	// served while the next one is collected.
+	mu        sync.RWMutex
 	stats     *collector.CollectReport
+	windows   windowReports
 	collector collector.Collector
 	constants config.Config
 }
@@ -32,6 +37,7 @@ var collectorCancel = make(chan bool)
 var serverCancel = make(chan bool)
 var errs = make(chan error)
 var timeNewTicker = time.NewTicker
+var timeNow = time.Now
 
 //var httpListenAndServe = http.ListenAndServe
 
@@ -40,9 +46,17 @@ func NewStatServer(constants config.Config) (ss Server) {
 	if constants.Cache == "" {
 		constants.Cache = cache.DefaultCache
 	}
+	cacheOptions := cache.GitCacheOptions{
+		Depth: constants.CloneDepth,
+		Quota: int64(constants.CacheQuota) << 20,
+	}
+	var c cache.Cache = cache.NewGitCacheWithOptions(constants.Cache, cacheOptions)
+	if constants.Cache == cache.MemoryCachePath {
+		c = cache.NewMemoryCache(cacheOptions)
+	}
 	//var cr *collector.CollectReport
 	s := &StatServer{
-		collector: collector.NewGitHubCloneCollector(constants, cache.NewGitCache(constants.Cache)),
+		collector: collector.NewGitHubCloneCollector(constants, c),
 		constants: constants,
 	}
 	cr := viper.Get("stats")
@@ -118,14 +132,14 @@ func (ss *StatServer) startServer(errs chan error) {
 }
 
 func (ss *StatServer) startCollector(errs chan error) {
-	var err error
 	// First Run....
 	logrus.Info("Bootstrapping Cache and Stats")
-	if ss.stats, err = ss.collector.Collect(); err != nil {
+	stats, err := ss.collector.Collect()
+	if err != nil {
 		errs <- err
 		return
 	}
-	ss.cacheStats()
+	ss.cacheStats(ss.publish(stats))
 	logrus.Info("Updated Cache and Stats")
 	// Ticker to run the job on an interval provided by the config file... defaults to 60 seconds...
 	ticker := timeNewTicker(time.Duration(ss.constants.Interval) * time.Second)
@@ -135,12 +149,16 @@ func (ss *StatServer) startCollector(errs chan error) {
 		for {
 			select {
 			case <-ticker.C:
-				if ss.stats, err = ss.collector.Collect(); err != nil {
-					errs <- err
+				// A failed collection leaves the last report served
+				stats, err := ss.collector.Collect()
+				if err != nil {
+					logrus.Errorf("Failed to collect stats, still serving the last report: %s", err)
+					continue
 				}
+				published := ss.publish(stats)
 				logrus.Info("Updated Cache and Stats")
 				// Cache stats to disk for later
-				ss.cacheStats()
+				ss.cacheStats(published)
 			}
 		}
 	}()
@@ -148,18 +166,92 @@ func (ss *StatServer) startCollector(errs chan error) {
 	return
 }
 
-func (ss *StatServer) cacheStats() (err error) {
+//publish makes a copy of a report the one served, as the next generation, and returns it.  The published report
+//must not be modified.
+func (ss *StatServer) publish(stats *collector.CollectReport) *collector.CollectReport {
+	published := *stats
+	now := timeNow().UTC()
+	published.Published = &now
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	published.Generation = 1
+	if ss.stats != nil {
+		published.Generation = ss.stats.Generation + 1
+	}
+	ss.stats = &published
+	return &published
+}
+
+//current returns the last published report, nil until the first collection is over
+func (ss *StatServer) current() *collector.CollectReport {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.stats
+}
 
-	viper.Set("stats", ss.stats)
+func (ss *StatServer) cacheStats(stats *collector.CollectReport) (err error) {
+
+	viper.Set("stats", stats)
 
 	return viper.WriteConfig()
 }
 
 func (ss *StatServer) statsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := ss.current()
+	// A time window in the query computes a report for it from the cache, once per collection
+	q := r.URL.Query()
+	if q.Get("since") != "" || q.Get("until") != "" {
+		var err error
+		var win collector.Window
+		if win.Since, win.Until, err = config.ParseWindow(q.Get("since"), q.Get("until"), timeNow()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var generation int64
+		if stats != nil {
+			generation = stats.Generation
+		}
+		stats, err = ss.windows.report(q.Get("since"), q.Get("until"), generation, func() (*collector.CollectReport, error) {
+			return ss.collector.Report(win)
+		})
+		if err == errBusy {
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, "too many stats being computed, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			logrus.Error(err)
+			http.Error(w, "failed to compute stats", http.StatusInternalServerError)
+			return
+		}
+	} else if ss.collector != nil {
+		// The results so far of a collection in progress are served alongside the last complete report, or alone
+		// until the first collection is over
+		if partial := ss.collector.Progress(); partial != nil {
+			if stats == nil {
+				stats = partial
+			} else {
+				current := *stats
+				current.Collecting = partial
+				stats = &current
+			}
+		}
+	}
+	if stats != nil && ss.constants.HideContributors {
+		// Copy the report rather than modifying the one being served
+		public := *stats
+		public.Contributors = nil
+		if public.Collecting != nil {
+			collecting := *public.Collecting
+			collecting.Contributors = nil
+			public.Collecting = &collecting
+		}
+		stats = &public
+	}
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
-	enc.Encode(ss.stats)
+	enc.Encode(stats)
 }
 
 func (ss *StatServer) cleanup() {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 
 	"encoding/json"
@@ -44,27 +45,39 @@ func setupSubTest(t *testing.T, origins bool) func(t *testing.T) {
 func TestNewStatServer(t *testing.T) {
 	teardown := setupTestCase(t)
 	defer teardown(t)
+	memory := constants
+	memory.Cache = cache.MemoryCachePath
 	tests := []struct {
-		name   string
-		wantSs Server
+		name      string
+		constants config.Config
+		wantSs    Server
 	}{
 		{
-			name: "OK",
+			name:      "OK",
+			constants: constants,
 			wantSs: &StatServer{
 				constants: constants,
 				collector: collector.NewGitHubCloneCollector(constants, cache.NewGitCache(constants.Cache)),
 			},
 		}, {
-			name: "OK - No Cache",
+			name:      "OK - No Cache",
+			constants: constants,
 			wantSs: &StatServer{
 				constants: constants,
 				collector: collector.NewGitHubCloneCollector(constants, cache.NewGitCache(constants.Cache)),
 			},
+		}, {
+			name:      "OK - Memory",
+			constants: memory,
+			wantSs: &StatServer{
+				constants: memory,
+				collector: collector.NewGitHubCloneCollector(memory, cache.NewMemoryCache(cache.GitCacheOptions{})),
+			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if gotSs := NewStatServer(constants); !reflect.DeepEqual(gotSs, tt.wantSs) {
+			if gotSs := NewStatServer(tt.constants); !reflect.DeepEqual(gotSs, tt.wantSs) {
 				t.Errorf("NewStatServer() = %v, want %v", gotSs, tt.wantSs)
 			}
 		})
@@ -253,16 +266,23 @@ func TestStatServer_cleanup(t *testing.T) {
 func TestStatServer_statsHandler(t *testing.T) {
 	teardown := setupTestCase(t)
 	defer teardown(t)
+	timeNow = func() time.Time {
+		return time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)
+	}
+	defer func() {
+		timeNow = time.Now
+	}()
 	type args struct {
 		w http.ResponseWriter
 		r *http.Request
 	}
-	w := httptest.NewRecorder()
 	tests := []struct {
-		name   string
-		ss     *StatServer
-		args   args
-		expect string
+		name       string
+		ss         *StatServer
+		query      string
+		args       args
+		expect     string
+		wantStatus int
 	}{
 		{
 			name: "OK",
@@ -272,22 +292,97 @@ func TestStatServer_statsHandler(t *testing.T) {
 					Commits:  0,
 					Lines:    0,
 					Projects: 0,
+					Complete: true,
+				},
+			},
+			expect:     `{"commits":0,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":true}`,
+			wantStatus: http.StatusOK,
+		}, {
+			name: "OK Hide Contributors",
+			ss: &StatServer{
+				constants: config.Config{HideContributors: true},
+				stats: &collector.CollectReport{
+					Commits:      1,
+					Complete:     true,
+					Contributors: []*collector.Contributor{{Identity: "jdoe", Commits: 1}},
+				},
+			},
+			expect:     `{"commits":1,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":true}`,
+			wantStatus: http.StatusOK,
+		}, {
+			name: "OK Bootstrapping",
+			ss: &StatServer{
+				collector: &MockCollector{progress: &collector.CollectReport{
+					Commits:  1,
+					Progress: &collector.Progress{Started: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Done: 1, Total: 2},
+				}},
+			},
+			expect:     `{"commits":1,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":false,"progress":{"started":"2018-01-01T00:00:00Z","done":1,"total":2}}`,
+			wantStatus: http.StatusOK,
+		}, {
+			name: "OK Collecting",
+			ss: &StatServer{
+				constants: config.Config{HideContributors: true},
+				stats: &collector.CollectReport{
+					Commits:      2,
+					Complete:     true,
+					Contributors: []*collector.Contributor{{Identity: "jdoe", Commits: 2}},
 				},
+				collector: &MockCollector{progress: &collector.CollectReport{
+					Commits:      1,
+					Contributors: []*collector.Contributor{{Identity: "jdoe", Commits: 1}},
+					Progress:     &collector.Progress{Started: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Done: 1, Total: 2, Current: "github.com/unorepo/dos"},
+				}},
+			},
+			expect:     `{"commits":2,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":true,"collecting":{"commits":1,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":false,"progress":{"started":"2018-01-01T00:00:00Z","done":1,"total":2,"current":"github.com/unorepo/dos"}}}`,
+			wantStatus: http.StatusOK,
+		}, {
+			name: "OK Window",
+			ss: &StatServer{
+				collector: &MockCollector{},
+			},
+			query:      "?since=2018-01-01",
+			expect:     `{"commits":1,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":true,"since":"2018-01-01T00:00:00Z"}`,
+			wantStatus: http.StatusOK,
+		}, {
+			name: "OK Relative Window",
+			ss: &StatServer{
+				collector: &MockCollector{},
+			},
+			query:      "?since=90d",
+			expect:     `{"commits":1,"additions":0,"deletions":0,"lines":0,"excluded":0,"bots":0,"projects":0,"complete":true,"since":"2018-01-01T00:00:00Z"}`,
+			wantStatus: http.StatusOK,
+		}, {
+			name: "Error Window",
+			ss: &StatServer{
+				collector: &MockCollector{},
 			},
-			expect: `{"commits":0,"lines":0,"projects":0}`,
+			query:      "?since=yesterday",
+			wantStatus: http.StatusBadRequest,
+		}, {
+			name: "Error Report",
+			ss: &StatServer{
+				collector: &MockCollector{wantErr: true},
+			},
+			query:      "?until=90d",
+			wantStatus: http.StatusInternalServerError,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
 			handler := http.HandlerFunc(tt.ss.statsHandler)
-			req, err := http.NewRequest("GET", "/", nil)
+			req, err := http.NewRequest("GET", "/"+tt.query, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
 			handler.ServeHTTP(w, req)
-			if status := w.Code; status != http.StatusOK {
+			if status := w.Code; status != tt.wantStatus {
 				t.Errorf("handler returned wrong status code: got %v want %v",
-					status, http.StatusOK)
+					status, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
 			}
 
 			// Check the response body is what we expect.
@@ -295,11 +390,130 @@ func TestStatServer_statsHandler(t *testing.T) {
 				t.Errorf("handler returned unexpected body: got %v want %v",
 					w.Body.String(), tt.expect)
 			}
+			// The served report must be left untouched
+			if tt.ss.stats != nil && tt.ss.constants.HideContributors && tt.ss.stats.Contributors == nil {
+				t.Errorf("handler modified the served report")
+			}
+			if mc, ok := tt.ss.collector.(*MockCollector); ok && mc.progress != nil && tt.ss.constants.HideContributors &&
+				mc.progress.Contributors == nil {
+				t.Errorf("handler modified the report of the collection in progress")
+			}
 
 		})
 	}
 }
 
+func TestStatServer_publish(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		return now
+	}
+	defer func() {
+		timeNow = time.Now
+	}()
+	ss := &StatServer{}
+	first := &collector.CollectReport{Commits: 1}
+	if got := ss.publish(first); got.Generation != 1 || !got.Published.Equal(now) || ss.current() != got {
+		t.Errorf("StatServer.publish() = %+v, want generation 1 published at %v", got, now)
+	}
+	if first.Generation != 0 || first.Published != nil {
+		t.Errorf("StatServer.publish() modified the report")
+	}
+	published := ss.current()
+	now = now.Add(time.Hour)
+	if got := ss.publish(&collector.CollectReport{Commits: 2}); got.Generation != 2 || !got.Published.Equal(now) ||
+		ss.current() != got {
+		t.Errorf("StatServer.publish() = %+v, want generation 2 published at %v", got, now)
+	}
+	if published.Generation != 1 || published.Commits != 1 {
+		t.Errorf("StatServer.publish() modified the previous report")
+	}
+}
+
+func TestStatServer_statsHandler_concurrent(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	c := make(chan time.Time)
+	timeNewTicker = func(d time.Duration) *time.Ticker {
+		return &time.Ticker{
+			C: c,
+		}
+	}
+	defer func() {
+		timeNewTicker = time.NewTicker
+	}()
+	const collections = 50
+	ss := &StatServer{
+		constants: config.Config{HideContributors: true},
+		collector: &MockCollector{},
+	}
+	go ss.startCollector(make(chan error, 1))
+	// Readers only ever see whole reports, the commits of each being its generation, and never an older one
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last int64
+			for last < collections+1 {
+				w := httptest.NewRecorder()
+				ss.statsHandler(w, httptest.NewRequest("GET", "/", nil))
+				var got collector.CollectReport
+				if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+					t.Error(err)
+					return
+				}
+				if got.Commits != got.Generation || got.Generation < last {
+					t.Errorf("handler served generation %d with %d commits after generation %d", got.Generation,
+						got.Commits, last)
+					return
+				}
+				last = got.Generation
+			}
+		}()
+	}
+	for i := 0; i < collections; i++ {
+		c <- time.Now()
+	}
+	wg.Wait()
+}
+
+func TestStatServer_startCollector_failed(t *testing.T) {
+	teardown := setupTestCase(t)
+	defer teardown(t)
+	c := make(chan time.Time)
+	timeNewTicker = func(d time.Duration) *time.Ticker {
+		return &time.Ticker{
+			C: c,
+		}
+	}
+	defer func() {
+		timeNewTicker = time.NewTicker
+	}()
+	ss := &StatServer{
+		constants: config.Config{HideContributors: true},
+		collector: &MockCollector{failFrom: 2},
+	}
+	// Nothing reads the errors once the server is up, failed collections don't block the next ones
+	go ss.startCollector(make(chan error))
+	for i := 0; i < 3; i++ {
+		select {
+		case c <- time.Now():
+		case <-time.After(10 * time.Second):
+			t.Fatalf("collection #%d blocked after a failed one", i+2)
+		}
+	}
+	w := httptest.NewRecorder()
+	ss.statsHandler(w, httptest.NewRequest("GET", "/", nil))
+	var got collector.CollectReport
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Generation != 1 || got.Commits != 1 {
+		t.Errorf("handler served generation %d with %d commits, want the first report", got.Generation, got.Commits)
+	}
+}
+
 func AreEqualJSON(s1, s2 string) bool {
 	var o1 interface{}
 	var o2 interface{}
@@ -319,12 +533,35 @@ func AreEqualJSON(s1, s2 string) bool {
 
 type MockCollector struct {
 	wantErr bool
+	// failFrom is the first collection that fails, when set
+	failFrom int64
+	// progress is the report of the collection in progress
+	progress *collector.CollectReport
+	// collected is the number of collections, which is the number of commits of each report
+	collected int64
 }
 
 func (mc *MockCollector) Collect() (stats *collector.CollectReport, err error) {
-	stats = &collector.CollectReport{}
-	if mc.wantErr {
+	mc.collected = mc.collected + 1
+	stats = &collector.CollectReport{Commits: mc.collected, Complete: true}
+	if mc.wantErr || (mc.failFrom > 0 && mc.collected >= mc.failFrom) {
 		err = errors.New("expected error")
 	}
 	return
 }
+
+func (mc *MockCollector) Report(w collector.Window) (stats *collector.CollectReport, err error) {
+	if mc.wantErr {
+		return nil, errors.New("expected error")
+	}
+	stats = &collector.CollectReport{
+		Commits:  1,
+		Complete: true,
+		Since:    &w.Since,
+	}
+	return
+}
+
+func (mc *MockCollector) Progress() (stats *collector.CollectReport) {
+	return mc.progress
+}
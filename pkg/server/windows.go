@@ -0,0 +1,68 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/thales-e-security/contribstats/pkg/collector"
+)
+
+// maxWindowReports is the number of reports of time windows computed at once, as each walks the whole cache
+var maxWindowReports = 2
+
+// maxCachedWindows is the number of reports of time windows kept until the next collection
+var maxCachedWindows = 32
+
+// errBusy is returned when too many reports of time windows are being computed already
+var errBusy = errors.New("too many reports being computed")
+
+//windowReports caches the reports of time windows computed from the cache, until the next collection.  Requests for a
+//window being computed wait for it rather than computing it again.  The zero value is ready to use.
+type windowReports struct {
+	sync.Mutex
+	generation int64
+	reports    map[string]*windowReport
+	computing  int
+}
+
+//windowReport is the report of a time window, once done is closed
+type windowReport struct {
+	done  chan struct{}
+	stats *collector.CollectReport
+	err   error
+}
+
+//report returns the report of the time window of a query, computing it unless it was since the collection of
+//generation.  errBusy is returned when too many other reports are being computed.  Failures aren't cached.
+func (wr *windowReports) report(since, until string, generation int64, compute func() (*collector.CollectReport, error)) (*collector.CollectReport, error) {
+	key := since + "\x00" + until
+	wr.Lock()
+	if wr.reports == nil || wr.generation != generation || len(wr.reports) >= maxCachedWindows {
+		wr.reports = make(map[string]*windowReport)
+		wr.generation = generation
+	}
+	r, ok := wr.reports[key]
+	if ok {
+		wr.Unlock()
+		<-r.done
+		return r.stats, r.err
+	}
+	if wr.computing >= maxWindowReports {
+		wr.Unlock()
+		return nil, errBusy
+	}
+	r = &windowReport{done: make(chan struct{})}
+	wr.reports[key] = r
+	wr.computing++
+	wr.Unlock()
+
+	r.stats, r.err = compute()
+	wr.Lock()
+	wr.computing--
+	if r.err != nil && wr.reports[key] == r {
+		delete(wr.reports, key)
+	}
+	wr.Unlock()
+	close(r.done)
+	return r.stats, r.err
+}
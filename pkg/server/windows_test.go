@@ -0,0 +1,90 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/thales-e-security/contribstats/pkg/collector"
+)
+
+func Test_windowReports_report(t *testing.T) {
+	var wr windowReports
+	computed := 0
+	compute := func() (*collector.CollectReport, error) {
+		computed++
+		return &collector.CollectReport{Commits: int64(computed)}, nil
+	}
+	tests := []struct {
+		name         string
+		since        string
+		generation   int64
+		compute      func() (*collector.CollectReport, error)
+		wantCommits  int64
+		wantComputed int
+		wantErr      bool
+	}{
+		{name: "First", since: "90d", generation: 1, compute: compute, wantCommits: 1, wantComputed: 1},
+		{name: "Cached", since: "90d", generation: 1, compute: compute, wantCommits: 1, wantComputed: 1},
+		{name: "Other Window", since: "1y", generation: 1, compute: compute, wantCommits: 2, wantComputed: 2},
+		{name: "Next Collection", since: "90d", generation: 2, compute: compute, wantCommits: 3, wantComputed: 3},
+		{
+			name:       "Error",
+			since:      "2018-01-01",
+			generation: 2,
+			compute: func() (*collector.CollectReport, error) {
+				return nil, errors.New("expected error")
+			},
+			wantComputed: 3,
+			wantErr:      true,
+		},
+		{name: "Error Not Cached", since: "2018-01-01", generation: 2, compute: compute, wantCommits: 4, wantComputed: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wr.report(tt.since, "", tt.generation, tt.compute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("windowReports.report() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != nil && got.Commits != tt.wantCommits {
+				t.Errorf("windowReports.report() commits = %v, want %v", got.Commits, tt.wantCommits)
+			}
+			if computed != tt.wantComputed {
+				t.Errorf("windowReports.report() computed %d reports, want %d", computed, tt.wantComputed)
+			}
+		})
+	}
+}
+
+func Test_windowReports_busy(t *testing.T) {
+	limit := maxWindowReports
+	maxWindowReports = 1
+	defer func() {
+		maxWindowReports = limit
+	}()
+	var wr windowReports
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan *collector.CollectReport)
+	go func() {
+		stats, _ := wr.report("90d", "", 1, func() (*collector.CollectReport, error) {
+			close(started)
+			<-release
+			return &collector.CollectReport{Commits: 1}, nil
+		})
+		done <- stats
+	}()
+	<-started
+	if _, err := wr.report("1y", "", 1, nil); err != errBusy {
+		t.Errorf("windowReports.report() error = %v, want %v", err, errBusy)
+	}
+	// Requests for the window being computed wait for it
+	waiting := make(chan *collector.CollectReport)
+	go func() {
+		stats, _ := wr.report("90d", "", 1, nil)
+		waiting <- stats
+	}()
+	close(release)
+	if first, second := <-done, <-waiting; first != second {
+		t.Errorf("windowReports.report() computed the window twice")
+	}
+}
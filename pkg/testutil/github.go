@@ -0,0 +1,375 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+//rateLimit is the number of requests an hour the GitHub fake allows, like GitHub does for authenticated clients
+const rateLimit = 5000
+
+//GitHub is a fake of the subset of the GitHub v3 API that contribstats uses, serving the repos of Repos on disk.
+//Point a client at URL, and Close it when done.
+type GitHub struct {
+	*httptest.Server
+
+	t         testing.TB
+	mu        sync.Mutex
+	nextID    int64
+	repos     map[string]*fakeRepo
+	orgs      map[string][]string
+	members   map[string][]string
+	users     map[string]*fakeUser
+	remaining int
+	requests  int
+}
+
+//fakeRepo is a repo served by the GitHub fake
+type fakeRepo struct {
+	repo *github.Repository
+	git  *Repo
+	// fields are served in addition to those of repo, e.g. the ones go-github doesn't know about
+	fields map[string]interface{}
+}
+
+//fakeUser is a user of the GitHub fake, with the email addresses its commits are linked by
+type fakeUser struct {
+	user   *github.User
+	emails map[string]bool
+}
+
+//NewGitHub starts a GitHub fake without any orgs, repos or users
+func NewGitHub(t testing.TB) *GitHub {
+	gh := &GitHub{
+		t:         t,
+		repos:     make(map[string]*fakeRepo),
+		orgs:      make(map[string][]string),
+		members:   make(map[string][]string),
+		users:     make(map[string]*fakeUser),
+		remaining: rateLimit,
+	}
+	gh.Server = httptest.NewServer(http.HandlerFunc(gh.serve))
+	return gh
+}
+
+//AddRepo serves a repo on disk as org/name, and returns it as listed by the API
+func (gh *GitHub) AddRepo(org, name string, repo *Repo) *github.Repository {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.nextID++
+	fullname := org + "/" + name
+	r := &github.Repository{
+		ID:       github.Int64(gh.nextID),
+		Name:     github.String(name),
+		FullName: github.String(fullname),
+		Owner:    &github.User{Login: github.String(org)},
+		CloneURL: github.String(repo.URL()),
+		HTMLURL:  github.String(gh.URL + "/" + fullname),
+	}
+	gh.repos[strings.ToLower(fullname)] = &fakeRepo{repo: r, git: repo}
+	gh.orgs[strings.ToLower(org)] = append(gh.orgs[strings.ToLower(org)], strings.ToLower(fullname))
+	return r
+}
+
+//SetRepoFields serves more fields of a repo, e.g. "disabled", "is_template" or "visibility", which override those of
+//the repo returned by AddRepo.  The fields of that repo may also be changed directly.
+func (gh *GitHub) SetRepoFields(fullname string, fields map[string]interface{}) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	repo, ok := gh.repos[strings.ToLower(fullname)]
+	if !ok {
+		gh.t.Fatalf("testutil: no repo %v", fullname)
+	}
+	if repo.fields == nil {
+		repo.fields = make(map[string]interface{})
+	}
+	for k, v := range fields {
+		repo.fields[k] = v
+	}
+}
+
+//AddUser adds a user, whose commits are those authored with any of emails.  The first email is public.
+func (gh *GitHub) AddUser(login string, emails ...string) *github.User {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.nextID++
+	u := &fakeUser{
+		user:   &github.User{ID: github.Int64(gh.nextID), Login: github.String(login)},
+		emails: make(map[string]bool),
+	}
+	if len(emails) > 0 {
+		u.user.Email = github.String(emails[0])
+	}
+	for _, email := range emails {
+		u.emails[strings.ToLower(email)] = true
+	}
+	gh.users[strings.ToLower(login)] = u
+	return u.user
+}
+
+//AddMember makes a user a member of an org
+func (gh *GitHub) AddMember(org, login string) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.members[strings.ToLower(org)] = append(gh.members[strings.ToLower(org)], strings.ToLower(login))
+}
+
+//SetRateLimit sets the number of requests left before the fake answers as GitHub does when rate limited
+func (gh *GitHub) SetRateLimit(remaining int) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.remaining = remaining
+}
+
+//Requests returns the number of requests served so far
+func (gh *GitHub) Requests() int {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	return gh.requests
+}
+
+//serve routes the API requests
+func (gh *GitHub) serve(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+	gh.requests++
+	reset := time.Now().Add(time.Hour).Unix()
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rateLimit))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+	if gh.remaining <= 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		gh.error(w, http.StatusForbidden, "API rate limit exceeded")
+		return
+	}
+	gh.remaining--
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(gh.remaining))
+	if r.Method != http.MethodGet {
+		gh.error(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	path := strings.Split(strings.Trim(strings.ToLower(r.URL.Path), "/"), "/")
+	switch {
+	case len(path) == 3 && path[0] == "orgs" && path[2] == "repos":
+		gh.orgRepos(w, r, path[1])
+	case len(path) == 3 && path[0] == "orgs" && path[2] == "members":
+		gh.orgMembers(w, r, path[1])
+	case len(path) == 3 && path[0] == "repos":
+		gh.repo(w, path[1]+"/"+path[2])
+	case len(path) == 4 && path[0] == "repos" && path[3] == "commits":
+		gh.commits(w, r, path[1]+"/"+path[2])
+	case len(path) == 2 && path[0] == "users":
+		gh.user(w, path[1])
+	default:
+		gh.error(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+//orgRepos serves the repos of an org
+func (gh *GitHub) orgRepos(w http.ResponseWriter, r *http.Request, org string) {
+	names, ok := gh.orgs[org]
+	if !ok {
+		gh.error(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	repos := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		repos = append(repos, gh.repos[name].json(gh.t))
+	}
+	start, end := gh.paginate(w, r, len(repos))
+	gh.json(w, repos[start:end])
+}
+
+//orgMembers serves the members of an org
+func (gh *GitHub) orgMembers(w http.ResponseWriter, r *http.Request, org string) {
+	if _, ok := gh.orgs[org]; !ok {
+		gh.error(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	logins := gh.members[org]
+	users := make([]*github.User, 0, len(logins))
+	for _, login := range logins {
+		users = append(users, &github.User{ID: gh.users[login].user.ID, Login: gh.users[login].user.Login})
+	}
+	start, end := gh.paginate(w, r, len(users))
+	gh.json(w, users[start:end])
+}
+
+//repo serves a repo
+func (gh *GitHub) repo(w http.ResponseWriter, fullname string) {
+	repo, ok := gh.repos[fullname]
+	if !ok {
+		gh.error(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	gh.json(w, repo.json(gh.t))
+}
+
+//json returns the fields of the repo served
+func (r *fakeRepo) json(t testing.TB) map[string]interface{} {
+	b, err := json.Marshal(r.repo)
+	if err != nil {
+		t.Error(err)
+	}
+	var fields map[string]interface{}
+	if err = json.Unmarshal(b, &fields); err != nil {
+		t.Error(err)
+	}
+	for k, v := range r.fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+//commits serves the commits of a repo from HEAD, newest first, optionally only those of the author login
+func (gh *GitHub) commits(w http.ResponseWriter, r *http.Request, fullname string) {
+	repo, ok := gh.repos[fullname]
+	if !ok {
+		gh.error(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var author *fakeUser
+	if login := r.URL.Query().Get("author"); login != "" {
+		if author, ok = gh.users[strings.ToLower(login)]; !ok {
+			gh.json(w, []*github.RepositoryCommit{})
+			return
+		}
+	}
+	iter, err := repo.git.Repository.Log(&git.LogOptions{})
+	if err != nil {
+		gh.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	commits := []*github.RepositoryCommit{}
+	if err = iter.ForEach(func(c *object.Commit) error {
+		user := gh.userByEmail(c.Author.Email)
+		if author != nil && user != author {
+			return nil
+		}
+		rc := &github.RepositoryCommit{
+			SHA: github.String(c.Hash.String()),
+			Commit: &github.Commit{
+				SHA:       github.String(c.Hash.String()),
+				Message:   github.String(c.Message),
+				Author:    commitAuthor(c.Author),
+				Committer: commitAuthor(c.Committer),
+			},
+		}
+		if user != nil {
+			rc.Author = &github.User{ID: user.user.ID, Login: user.user.Login}
+		}
+		commits = append(commits, rc)
+		return nil
+	}); err != nil {
+		gh.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	start, end := gh.paginate(w, r, len(commits))
+	gh.json(w, commits[start:end])
+}
+
+//user serves a user
+func (gh *GitHub) user(w http.ResponseWriter, login string) {
+	user, ok := gh.users[login]
+	if !ok {
+		gh.error(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	gh.json(w, user.user)
+}
+
+//userByEmail returns the user that commits with an email, or nil
+func (gh *GitHub) userByEmail(email string) *fakeUser {
+	for _, user := range gh.users {
+		if user.emails[strings.ToLower(email)] {
+			return user
+		}
+	}
+	return nil
+}
+
+//paginate returns the range of n items on the requested page, and links the other pages like GitHub does
+func (gh *GitHub) paginate(w http.ResponseWriter, r *http.Request, n int) (start, end int) {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	last := (n + perPage - 1) / perPage
+	link := func(page int, rel string) string {
+		u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", strconv.Itoa(perPage))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+	}
+	var links []string
+	if page < last {
+		links = append(links, link(page+1, "next"), link(last, "last"))
+	}
+	if page > 1 {
+		links = append(links, link(1, "first"), link(page-1, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	start = (page - 1) * perPage
+	if start > n {
+		start = n
+	}
+	end = start + perPage
+	if end > n {
+		end = n
+	}
+	return
+}
+
+//json writes v as the response
+func (gh *GitHub) json(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		gh.t.Error(err)
+	}
+}
+
+//error writes an error response like GitHub's
+func (gh *GitHub) error(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":           message,
+		"documentation_url": "https://developer.github.com/v3",
+	})
+}
+
+//commitAuthor returns a git signature as the API does
+func commitAuthor(sig object.Signature) *github.CommitAuthor {
+	when := sig.When
+	return &github.CommitAuthor{
+		Name:  github.String(sig.Name),
+		Email: github.String(sig.Email),
+		Date:  &when,
+	}
+}
@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestGitHub(t *testing.T) {
+	td, err := ioutil.TempDir("", "testutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	gh := NewGitHub(t)
+	defer gh.Close()
+	var want []string
+	for _, name := range []string{"uno", "dos", "tres"} {
+		repo := NewDiskRepo(t, filepath.Join(td, name))
+		repo.Commit(Commit{Author: Sig("Jane", "jane@example.com")})
+		repo.Commit(Commit{Author: Sig("John", "john@example.com")})
+		want = append(want, gh.AddRepo("unorepo", name, repo).GetCloneURL())
+	}
+	gh.AddUser("jane", "jane@example.com")
+	gh.AddMember("unorepo", "jane")
+	client, err := github.NewEnterpriseClient(gh.URL, gh.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	opt := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 2}}
+	var got []string
+	for {
+		repos, resp, err := client.Repositories.ListByOrg(ctx, "unorepo", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, repo := range repos {
+			got = append(got, repo.GetCloneURL())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[2] != want[2] {
+		t.Errorf("ListByOrg() = %v, want %v", got, want)
+	}
+	if _, _, err = client.Repositories.ListByOrg(ctx, "nothere", nil); err == nil {
+		t.Error("ListByOrg() of a missing org didn't fail")
+	}
+
+	repo, _, err := client.Repositories.Get(ctx, "unorepo", "dos")
+	if err != nil || repo.GetCloneURL() != want[1] || repo.GetID() == 0 {
+		t.Errorf("Get() = %v, %v", repo, err)
+	}
+	members, _, err := client.Organizations.ListMembers(ctx, "unorepo", nil)
+	if err != nil || len(members) != 1 || members[0].GetLogin() != "jane" {
+		t.Errorf("ListMembers() = %v, %v", members, err)
+	}
+	user, _, err := client.Users.Get(ctx, "jane")
+	if err != nil || user.GetEmail() != "jane@example.com" {
+		t.Errorf("Users.Get() = %v, %v", user, err)
+	}
+	commits, _, err := client.Repositories.ListCommits(ctx, "unorepo", "uno", &github.CommitsListOptions{Author: "jane"})
+	if err != nil || len(commits) != 1 || commits[0].GetCommit().GetAuthor().GetEmail() != "jane@example.com" ||
+		commits[0].GetAuthor().GetLogin() != "jane" {
+		t.Errorf("ListCommits() = %v, %v", commits, err)
+	}
+	if commits, _, err = client.Repositories.ListCommits(ctx, "unorepo", "uno", nil); err != nil || len(commits) != 2 {
+		t.Errorf("ListCommits() = %v, %v", commits, err)
+	}
+
+	gh.SetRateLimit(1)
+	_, resp, err := client.Users.Get(ctx, "jane")
+	if err != nil || resp.Rate.Limit != 5000 || resp.Rate.Remaining != 0 {
+		t.Errorf("Users.Get() rate = %v, %v", resp.Rate, err)
+	}
+	if _, _, err = client.Users.Get(ctx, "jane"); err == nil {
+		t.Error("Users.Get() wasn't rate limited")
+	} else if _, ok := err.(*github.RateLimitError); !ok {
+		t.Errorf("Users.Get() error = %T, want *github.RateLimitError", err)
+	}
+	if gh.Requests() == 0 {
+		t.Error("Requests() = 0")
+	}
+}
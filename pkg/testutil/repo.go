@@ -0,0 +1,215 @@
+//Package testutil builds git repositories with synthetic histories, so stats can be tested offline and against
+//exact numbers.
+package testutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+//Epoch is the time of the first commit of a Repo, unless its author says otherwise
+var Epoch = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+//Repo is a git repository with a working tree that commits are built in.  Every failure is fatal to the test.
+type Repo struct {
+	// Repository is the built repository
+	Repository *git.Repository
+	// Path is the location of the repository on disk, or empty when it's in memory
+	Path string
+
+	t    testing.TB
+	wt   *git.Worktree
+	when time.Time
+}
+
+//Commit describes a commit to build.  The changes are applied in the order Files, Binary, Rename, Delete.
+type Commit struct {
+	// Author of the commit, a zero When is an hour after the previous commit
+	Author object.Signature
+	// Committer of the commit, nil is the author
+	Committer *object.Signature
+	// Message of the commit, empty is "Commit"
+	Message string
+	// Trailers are appended to the message after a blank line, e.g. "Co-authored-by: Jane <jane@example.com>"
+	Trailers []string
+	// Files are written with their text content
+	Files map[string]string
+	// Binary files are written with their content, which should contain a NUL byte to be detected as binary
+	Binary map[string][]byte
+	// Rename moves files, from the keys to the values
+	Rename map[string]string
+	// Delete removes files
+	Delete []string
+	// Parents of the commit, nil is HEAD.  A merge has several, and its tree is the working tree, so its Files
+	// should carry the changes merged in.
+	Parents []plumbing.Hash
+}
+
+//NewRepo returns an empty Repo in memory
+func NewRepo(t testing.TB) *Repo {
+	rep, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRepo(t, rep, "")
+}
+
+//NewDiskRepo returns an empty Repo at path, which can be cloned from with URL
+func NewDiskRepo(t testing.TB, path string) *Repo {
+	rep, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRepo(t, rep, path)
+}
+
+func newRepo(t testing.TB, rep *git.Repository, path string) *Repo {
+	wt, err := rep.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Repo{
+		Repository: rep,
+		Path:       path,
+		t:          t,
+		wt:         wt,
+		when:       Epoch.Add(-time.Hour),
+	}
+}
+
+//URL returns the clone URL of a Repo on disk
+func (r *Repo) URL() string {
+	if r.Path == "" {
+		r.t.Fatal("testutil: a Repo in memory can't be cloned")
+	}
+	return "file://" + r.Path
+}
+
+//Commit builds a commit on HEAD and returns its hash
+func (r *Repo) Commit(c Commit) plumbing.Hash {
+	for name, content := range c.Files {
+		r.write(name, []byte(content))
+	}
+	for name, content := range c.Binary {
+		r.write(name, content)
+	}
+	for from, to := range c.Rename {
+		if _, err := r.wt.Move(from, to); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	for _, name := range c.Delete {
+		if _, err := r.wt.Remove(name); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	author := c.Author
+	if author.When.IsZero() {
+		r.when = r.when.Add(time.Hour)
+		author.When = r.when
+	} else {
+		r.when = author.When
+	}
+	committer := author
+	if c.Committer != nil {
+		committer = *c.Committer
+		if committer.When.IsZero() {
+			committer.When = author.When
+		}
+	}
+	message := c.Message
+	if message == "" {
+		message = "Commit"
+	}
+	if len(c.Trailers) > 0 {
+		message = strings.TrimRight(message, "\n") + "\n\n" + strings.Join(c.Trailers, "\n") + "\n"
+	}
+	hash, err := r.wt.Commit(message, &git.CommitOptions{
+		Author:    &author,
+		Committer: &committer,
+		Parents:   c.Parents,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return hash
+}
+
+//write writes a file to the working tree and stages it
+func (r *Repo) write(name string, content []byte) {
+	if err := util.WriteFile(r.wt.Filesystem, name, content, 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := r.wt.Add(name); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+//Branch creates a branch at HEAD and checks it out
+func (r *Repo) Branch(name string) {
+	head, err := r.Repository.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := r.wt.Checkout(&git.CheckoutOptions{
+		Hash:   head.Hash(),
+		Branch: plumbing.ReferenceName("refs/heads/" + name),
+		Create: true,
+	}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+//Checkout checks out an existing branch
+func (r *Repo) Checkout(name string) {
+	if err := r.wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.ReferenceName("refs/heads/" + name),
+	}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+//SetRef points a reference at a commit, e.g. "refs/remotes/origin/feature"
+func (r *Repo) SetRef(name string, hash plumbing.Hash) {
+	if err := r.Repository.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), hash)); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+//Tag tags a commit, with an annotated tag when there's a message
+func (r *Repo) Tag(name string, hash plumbing.Hash, message string) {
+	if message == "" {
+		r.SetRef("refs/tags/"+name, hash)
+		return
+	}
+	r.when = r.when.Add(time.Hour)
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     object.Signature{Name: "Tagger", Email: "tagger@example.com", When: r.when},
+		Message:    message,
+		TargetType: plumbing.CommitObject,
+		Target:     hash,
+	}
+	obj := r.Repository.Storer.NewEncodedObject()
+	if err := tag.Encode(obj); err != nil {
+		r.t.Fatal(err)
+	}
+	tagHash, err := r.Repository.Storer.SetEncodedObject(obj)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.SetRef("refs/tags/"+name, tagHash)
+}
+
+//Sig returns a signature for an author or committer, with a zero When
+func Sig(name, email string) object.Signature {
+	return object.Signature{Name: name, Email: email}
+}
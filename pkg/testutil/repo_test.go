@@ -0,0 +1,138 @@
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestRepo_Commit(t *testing.T) {
+	repo := NewRepo(t)
+	jane := Sig("Jane", "jane@thalesesec.net")
+	john := Sig("John", "john@example.com")
+	first := repo.Commit(Commit{
+		Author: jane,
+		Files:  map[string]string{"foo": "1\n2\n"},
+		Binary: map[string][]byte{"bin": {0, 1, 2}},
+	})
+	repo.Branch("feature")
+	feature := repo.Commit(Commit{
+		Author:    john,
+		Committer: &jane,
+		Message:   "Rename foo",
+		Trailers:  []string{"Co-authored-by: Jane <jane@thalesesec.net>"},
+		Rename:    map[string]string{"foo": "bar"},
+		Delete:    []string{"bin"},
+	})
+	repo.Checkout("master")
+	merge := repo.Commit(Commit{
+		Author:  jane,
+		Message: "Merge branch 'feature'",
+		Parents: []plumbing.Hash{first, feature},
+	})
+
+	c := commit(t, repo, feature)
+	if c.Author.Email != john.Email || c.Committer.Email != jane.Email {
+		t.Errorf("Commit() author = %v, committer = %v", c.Author.Email, c.Committer.Email)
+	}
+	if want := Epoch.Add(time.Hour); !c.Author.When.Equal(want) || !c.Committer.When.Equal(want) {
+		t.Errorf("Commit() when = %v, want %v", c.Author.When, want)
+	}
+	if want := "Rename foo\n\nCo-authored-by: Jane <jane@thalesesec.net>\n"; c.Message != want {
+		t.Errorf("Commit() message = %q, want %q", c.Message, want)
+	}
+	if got := files(t, c); !reflect.DeepEqual(got, map[string]string{"bar": "1\n2\n"}) {
+		t.Errorf("Commit() files = %v", got)
+	}
+	c = commit(t, repo, first)
+	if got := files(t, c); !reflect.DeepEqual(got, map[string]string{"foo": "1\n2\n", "bin": "\x00\x01\x02"}) {
+		t.Errorf("Commit() files = %v", got)
+	}
+	if c.Message != "Commit" || !c.Author.When.Equal(Epoch) {
+		t.Errorf("Commit() message = %q, when = %v", c.Message, c.Author.When)
+	}
+	c = commit(t, repo, merge)
+	if !reflect.DeepEqual(c.ParentHashes, []plumbing.Hash{first, feature}) {
+		t.Errorf("Commit() parents = %v", c.ParentHashes)
+	}
+	head, err := repo.Repository.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name() != "refs/heads/master" || head.Hash() != merge {
+		t.Errorf("Commit() HEAD = %v", head)
+	}
+}
+
+func TestRepo_Tag(t *testing.T) {
+	repo := NewRepo(t)
+	hash := repo.Commit(Commit{Author: Sig("Jane", "jane@thalesesec.net")})
+	repo.Tag("light", hash, "")
+	repo.Tag("annotated", hash, "Release\n")
+	ref, err := repo.Repository.Reference("refs/tags/light", false)
+	if err != nil || ref.Hash() != hash {
+		t.Errorf("Tag() light = %v, %v", ref, err)
+	}
+	if ref, err = repo.Repository.Reference("refs/tags/annotated", false); err != nil {
+		t.Fatal(err)
+	}
+	tag, err := repo.Repository.TagObject(ref.Hash())
+	if err != nil || tag.Target != hash || tag.Message != "Release\n" {
+		t.Errorf("Tag() annotated = %v, %v", tag, err)
+	}
+}
+
+func TestNewDiskRepo(t *testing.T) {
+	td, err := ioutil.TempDir("", "testutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	repo := NewDiskRepo(t, td)
+	hash := repo.Commit(Commit{Author: Sig("Jane", "jane@thalesesec.net"), Files: map[string]string{"foo": "foo\n"}})
+	if !strings.HasPrefix(repo.URL(), "file://") {
+		t.Errorf("URL() = %v", repo.URL())
+	}
+	clone, err := git.PlainClone(td+".clone", true, &git.CloneOptions{URL: repo.URL()})
+	defer os.RemoveAll(td + ".clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := clone.Head()
+	if err != nil || head.Hash() != hash {
+		t.Errorf("clone HEAD = %v, %v, want %v", head, err, hash)
+	}
+}
+
+//commit returns a commit of the repo
+func commit(t *testing.T, repo *Repo, hash plumbing.Hash) *object.Commit {
+	c, err := repo.Repository.CommitObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+//files returns the contents of the files of a commit
+func files(t *testing.T, c *object.Commit) map[string]string {
+	iter, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	if err = iter.ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		got[f.Name] = content
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}